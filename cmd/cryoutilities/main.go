@@ -19,16 +19,40 @@ package main
 import (
 	"context"
 	"cryoutilities/internal"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/cristalhq/acmd"
 )
 
 func main() {
+	// If no args are passed, assume "gui"
+	if len(os.Args) <= 1 {
+		os.Args = []string{"", "gui"}
+	}
+
+	// Hand off to an already running GUI before touching its log file
+	if os.Args[1] == "gui" {
+		activated, err := internal.ActivateRunningInstance(os.Args[2:])
+		if activated {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(internal.ExitCode(err))
+			}
+			return
+		}
+		// Keep a panic the handler missed last time, before its log is replaced
+		internal.CollectUnhandledCrash()
+	}
+
 	// Delete old log file
 	os.Remove(internal.LogFilePath)
 	// Create a log file
@@ -37,11 +61,15 @@ func main() {
 		log.Panic(err)
 	}
 	defer logFile.Close()
-	log.SetOutput(logFile)
 
 	// Create loggers
-	internal.CryoUtils.InfoLog = log.New(logFile, "INFO\t", log.Ldate|log.Ltime)
-	internal.CryoUtils.ErrorLog = log.New(logFile, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	logWriter := internal.LogWriter(logFile)
+	log.SetOutput(logWriter)
+	internal.CryoUtils.InfoLog = log.New(logWriter, "INFO\t", log.Ldate|log.Ltime)
+	internal.CryoUtils.ErrorLog = log.New(logWriter, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+
+	// Write a crash report instead of dying silently
+	defer internal.RecoverAndReport()
 
 	// Print the current version as a test
 	internal.CryoUtils.InfoLog.Println("Current Version:", internal.CurrentVersionNumber)
@@ -50,22 +78,28 @@ func main() {
 	cmds := []acmd.Command{
 		{
 			Name:        "gui",
-			Description: "Run in GUI mode",
-			ExecFunc: func(context.Context, []string) error {
-				internal.InitUI()
+			Description: "Run in GUI mode. Optionally accepts a tab to open, ex: 'storage'.",
+			ExecFunc: func(_ context.Context, args []string) error {
+				internal.InitUI(args)
 				return nil
 			},
 		},
 		{
 			Name:        "swap",
 			Description: "Change swap file size in increments of 1GB.",
-			ExecFunc: func(_ context.Context, args []string) error {
+			ExecFunc: func(ctx context.Context, args []string) error {
 				internal.CryoUtils.InfoLog.Println("Starting swap file resize...")
-				size, err := strconv.Atoi(args[0])
+				arg, err := singleArg(args)
 				if err != nil {
 					return err
 				}
-				err = internal.ChangeSwapSizeCLI(size, false)
+				size, err := strconv.Atoi(arg)
+				if err != nil || size < 1 {
+					return fmt.Errorf("%w: swap size must be a whole number of GB", internal.ErrInvalidArgument)
+				}
+				err = internal.WithOperationLock(internal.OperationSwap, func() error {
+					return internal.ChangeSwapSizeCLI(ctx, size, false)
+				})
 				if err != nil {
 					return err
 				}
@@ -78,12 +112,17 @@ func main() {
 			Description: "Change swappiness to the specified value 0-200.",
 			ExecFunc: func(_ context.Context, args []string) error {
 				internal.CryoUtils.InfoLog.Println("Starting swappiness change...")
-				swappiness := args[0]
+				swappiness, err := singleArg(args)
+				if err != nil {
+					return err
+				}
 				swappinessInt, err := strconv.Atoi(swappiness)
 				if err != nil || swappinessInt < 0 || swappinessInt > 200 {
-					return errors.New("invalid swappiness value")
+					return fmt.Errorf("%w: swappiness must be between 0 and 200", internal.ErrInvalidArgument)
 				}
-				err = internal.ChangeSwappiness(swappiness)
+				err = internal.WithOperationLock(internal.OperationTweaks, func() error {
+					return internal.ChangeSwappiness(swappiness)
+				})
 				if err != nil {
 					return err
 				}
@@ -95,21 +134,25 @@ func main() {
 			Name:        "hugepages",
 			Description: "Enable or disable hugepages. Accepts 'true', 'false', 'enable' or 'disable'.\n\tRecommended: Enabled",
 			ExecFunc: func(_ context.Context, args []string) error {
-				arg := strings.ToLower(args[0])
+				arg, err := singleArg(args)
+				if err != nil {
+					return err
+				}
+				arg = strings.ToLower(arg)
 				if arg == "true" || arg == "enable" {
 					internal.CryoUtils.InfoLog.Println("Enabling HugePages...")
-					err := internal.SetHugePages()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.SetHugePages)
 					if err != nil {
 						return err
 					}
 				} else if arg == "false" || arg == "disable" {
 					internal.CryoUtils.InfoLog.Println("Disabling HugePages...")
-					err := internal.RevertHugePages()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.RevertHugePages)
 					if err != nil {
 						return err
 					}
 				} else {
-					return errors.New("invalid argument provided")
+					return fmt.Errorf("%w: %q", internal.ErrInvalidArgument, arg)
 				}
 				return nil
 			},
@@ -118,21 +161,25 @@ func main() {
 			Name:        "compaction_proactiveness",
 			Description: "Set or revert compaction proactiveness. Accepts 'recommended' or 'stock'.",
 			ExecFunc: func(_ context.Context, args []string) error {
-				arg := strings.ToLower(args[0])
+				arg, err := singleArg(args)
+				if err != nil {
+					return err
+				}
+				arg = strings.ToLower(arg)
 				if arg == "recommended" {
 					internal.CryoUtils.InfoLog.Println("Setting Compaction Proactiveness...")
-					err := internal.SetCompactionProactiveness()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.SetCompactionProactiveness)
 					if err != nil {
 						return err
 					}
 				} else if arg == "stock" {
 					internal.CryoUtils.InfoLog.Println("Reverting Compaction Proactiveness...")
-					err := internal.RevertCompactionProactiveness()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.RevertCompactionProactiveness)
 					if err != nil {
 						return err
 					}
 				} else {
-					return errors.New("invalid argument provided")
+					return fmt.Errorf("%w: %q", internal.ErrInvalidArgument, arg)
 				}
 				return nil
 			},
@@ -141,21 +188,25 @@ func main() {
 			Name:        "defrag",
 			Description: "Enable or disable hugepage defrag. Accepts 'true', 'false', 'enable' or 'disable'.\n\tRecommended: Disabled",
 			ExecFunc: func(_ context.Context, args []string) error {
-				arg := strings.ToLower(args[0])
+				arg, err := singleArg(args)
+				if err != nil {
+					return err
+				}
+				arg = strings.ToLower(arg)
 				if arg == "true" || arg == "enable" {
 					internal.CryoUtils.InfoLog.Println("Enabling HugePAge Defrag...")
-					err := internal.RevertDefrag()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.RevertDefrag)
 					if err != nil {
 						return err
 					}
 				} else if arg == "false" || arg == "disable" {
 					internal.CryoUtils.InfoLog.Println("Revert Compaction Proactiveness...")
-					err := internal.SetDefrag()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.SetDefrag)
 					if err != nil {
 						return err
 					}
 				} else {
-					return errors.New("invalid argument provided")
+					return fmt.Errorf("%w: %q", internal.ErrInvalidArgument, arg)
 				}
 				return nil
 			},
@@ -164,21 +215,25 @@ func main() {
 			Name:        "page_lock_unfairness",
 			Description: "Set or revert page lock unfairness. Accepts 'recommended' or 'stock'.",
 			ExecFunc: func(_ context.Context, args []string) error {
-				arg := strings.ToLower(args[0])
+				arg, err := singleArg(args)
+				if err != nil {
+					return err
+				}
+				arg = strings.ToLower(arg)
 				if arg == "recommended" {
 					internal.CryoUtils.InfoLog.Println("Setting Page Lock Unfairness...")
-					err := internal.SetPageLockUnfairness()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.SetPageLockUnfairness)
 					if err != nil {
 						return err
 					}
 				} else if arg == "stock" {
 					internal.CryoUtils.InfoLog.Println("Reverting Page Lock Unfairness...")
-					err := internal.RevertPageLockUnfairness()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.RevertPageLockUnfairness)
 					if err != nil {
 						return err
 					}
 				} else {
-					return errors.New("invalid argument provided")
+					return fmt.Errorf("%w: %q", internal.ErrInvalidArgument, arg)
 				}
 				return nil
 			},
@@ -187,30 +242,465 @@ func main() {
 			Name:        "shmem",
 			Description: "Enable or disable shared memory. Accepts 'true', 'false', 'enable' or 'disable'.\n\tRecommended: Enabled",
 			ExecFunc: func(_ context.Context, args []string) error {
-				arg := strings.ToLower(args[0])
+				arg, err := singleArg(args)
+				if err != nil {
+					return err
+				}
+				arg = strings.ToLower(arg)
 				if arg == "true" || arg == "enable" {
 					internal.CryoUtils.InfoLog.Println("Setting Shared Memory...")
-					err := internal.SetShMem()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.SetShMem)
 					if err != nil {
 						return err
 					}
 				} else if arg == "false" || arg == "disable" {
 					internal.CryoUtils.InfoLog.Println("Reverting Shared Memory...")
-					err := internal.RevertShMem()
+					err = internal.WithOperationLock(internal.OperationTweaks, internal.RevertShMem)
 					if err != nil {
 						return err
 					}
 				} else {
-					return errors.New("invalid argument provided")
+					return fmt.Errorf("%w: %q", internal.ErrInvalidArgument, arg)
 				}
 				return nil
 			},
 		},
+		{
+			Name: "verify",
+			Description: "Set how copied game data is checked before the original is deleted. " +
+				"Accepts 'off', 'metadata' or 'full'.\n\tPrints the current mode if no mode is given.",
+			ExecFunc: func(_ context.Context, args []string) error {
+				if len(args) == 0 {
+					fmt.Println(internal.GetVerifyMode())
+					return nil
+				}
+				mode, err := singleArg(args)
+				if err != nil {
+					return err
+				}
+				return internal.SetVerifyMode(strings.ToLower(mode))
+			},
+		},
+		{
+			Name:        "links",
+			Description: "Check and repair the compatdata and shadercache links on the SSD.",
+			Subcommands: []acmd.Command{
+				{
+					Name:        "scan",
+					Description: "List every link, and what's wrong with the broken ones.",
+					ExecFunc: func(_ context.Context, _ []string) error {
+						links, err := internal.ScanDataLinks()
+						if err != nil {
+							return err
+						}
+						for _, link := range links {
+							if link.Status == internal.LinkStatusDirectory {
+								continue
+							}
+							line := fmt.Sprintf("%-24s %s -> %s", link.Status, link.Path, link.Target)
+							if link.Candidate != "" {
+								line += " (found at " + link.Candidate + ")"
+							}
+							fmt.Println(line)
+						}
+						return nil
+					},
+				},
+				{
+					Name: "repair",
+					Description: "Repair broken links with 'recreate', 'repoint' or 'remove', " +
+						"optionally only for the given appids.",
+					ExecFunc: func(_ context.Context, args []string) error {
+						if len(args) == 0 {
+							return fmt.Errorf("%w: expected a repair and optional appids", internal.ErrInvalidArgument)
+						}
+						// Cards that were only mounted somewhere new are fixed without asking
+						err := internal.UpdateDriveRegistry()
+						if err != nil {
+							return err
+						}
+						return internal.WithOperationLock(internal.OperationGameData, func() error {
+							return repairLinks(strings.ToLower(args[0]), args[1:])
+						})
+					},
+				},
+			},
+		},
+		{
+			Name: "games",
+			Description: "List, locate, sync and clean game data. Add --json for machine-readable output,\n\t" +
+				"or --refresh to look game names up in the Steam API again.",
+			Subcommands: []acmd.Command{
+				{
+					Name:        "list",
+					Description: "List every game with compatdata or shadercache on an attached drive.",
+					ExecFunc: func(ctx context.Context, args []string) error {
+						flags, rest := parseFlags(args, "--json", "--refresh")
+						if len(rest) != 0 {
+							return fmt.Errorf("%w: unexpected %q", internal.ErrInvalidArgument, rest[0])
+						}
+						err := refreshAppNames(ctx, flags["--refresh"])
+						if err != nil {
+							return err
+						}
+						games, err := internal.ListGames(ctx)
+						if err != nil {
+							return err
+						}
+						if flags["--json"] {
+							return printJSON(games)
+						}
+						w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+						fmt.Fprintln(w, "APPID\tNAME\tINSTALLED\tLOCATION\tCOMPATDATA\tSHADERCACHE")
+						for _, game := range games {
+							fmt.Fprintf(w, "%d\t%s\t%t\t%s\t%s\t%s\n", game.AppID, game.Name, game.Installed,
+								game.Location, internal.GetHumanByteSize(game.CompatSize),
+								internal.GetHumanByteSize(game.ShaderSize))
+						}
+						return w.Flush()
+					},
+				},
+				{
+					Name: "report",
+					Description: "Report the space every game's data takes, e.g. 'games report --format csv'.\n\t" +
+						"--format accepts 'markdown', 'csv' or 'json', --sort accepts 'size', 'appid' or 'name'.",
+					ExecFunc: func(ctx context.Context, args []string) error {
+						flags, rest := parseFlags(args, "--refresh")
+						options, rest, err := parseValueFlags(rest, "--format", "--sort")
+						if err != nil {
+							return err
+						}
+						if len(rest) != 0 {
+							return fmt.Errorf("%w: unexpected %q", internal.ErrInvalidArgument, rest[0])
+						}
+						format, sortBy := internal.ReportFormatMarkdown, internal.ReportSortSize
+						if options["--format"] != "" {
+							format = strings.ToLower(options["--format"])
+						}
+						if options["--sort"] != "" {
+							sortBy = strings.ToLower(options["--sort"])
+						}
+						if !internal.Contains(internal.ReportFormats, format) {
+							return fmt.Errorf("%w: format must be one of %v", internal.ErrInvalidArgument,
+								internal.ReportFormats)
+						}
+						err = refreshAppNames(ctx, flags["--refresh"])
+						if err != nil {
+							return err
+						}
+						games, err := internal.GetStorageReport(ctx, sortBy, false)
+						if err != nil {
+							return err
+						}
+						return internal.WriteStorageReport(os.Stdout, games, format)
+					},
+				},
+				{
+					Name:        "where",
+					Description: "Show where a game's compatdata and shadercache are, e.g. 'games where 620'.",
+					ExecFunc: func(ctx context.Context, args []string) error {
+						flags, rest := parseFlags(args, "--json", "--refresh")
+						arg, err := singleArg(rest)
+						if err != nil {
+							return err
+						}
+						appID, err := strconv.Atoi(arg)
+						if err != nil {
+							return fmt.Errorf("%w: %q is not an appid", internal.ErrInvalidArgument, arg)
+						}
+						err = refreshAppNames(ctx, flags["--refresh"])
+						if err != nil {
+							return err
+						}
+						game, err := internal.FindGame(ctx, appID)
+						if err != nil {
+							return err
+						}
+						if flags["--json"] {
+							return printJSON(game)
+						}
+						fmt.Printf("%d - %s\n", game.AppID, game.Name)
+						if game.InstallDir != "" {
+							fmt.Printf("installed:   %s (%s, updated %s)\n", game.InstallDir,
+								internal.GetHumanByteSize(game.SizeOnDisk), game.LastUpdated.Format(time.DateOnly))
+						}
+						if game.CompatPath != "" {
+							fmt.Printf("compatdata:  %s (%s)\n", game.CompatPath,
+								internal.GetHumanByteSize(game.CompatSize))
+						}
+						if game.ShaderPath != "" {
+							fmt.Printf("shadercache: %s (%s)\n", game.ShaderPath,
+								internal.GetHumanByteSize(game.ShaderSize))
+						}
+						return nil
+					},
+				},
+				{
+					Name: "sync",
+					Description: "Sync game data between two drives, e.g. 'games sync ssd mmcblk0p1 --dry-run'.\n\t" +
+						"Drives are 'ssd', a mount path or a card's label.",
+					ExecFunc: func(ctx context.Context, args []string) error {
+						flags, rest := parseFlags(args, "--json", "--dry-run")
+						if len(rest) != 2 {
+							return fmt.Errorf("%w: expected <from> <to>", internal.ErrInvalidArgument)
+						}
+						from, err := internal.ResolveDrive(rest[0])
+						if err != nil {
+							return err
+						}
+						to, err := internal.ResolveDrive(rest[1])
+						if err != nil {
+							return err
+						}
+						if from == to {
+							return fmt.Errorf("%w: can't sync a drive with itself", internal.ErrInvalidArgument)
+						}
+
+						var preview *internal.SyncPreview
+						sync := func() error {
+							var onProgress func(internal.CopyProgress)
+							if !flags["--json"] {
+								onProgress = func(progress internal.CopyProgress) {
+									fmt.Printf("\r%-80s", progress.String())
+								}
+							}
+							preview, err = internal.SyncGames(ctx, from, to, flags["--dry-run"], onProgress)
+							return err
+						}
+						// A dry run changes nothing, so it doesn't need to wait for other operations
+						if flags["--dry-run"] {
+							err = sync()
+						} else {
+							err = internal.WithOperationLock(internal.OperationGameData, sync)
+						}
+						if preview == nil {
+							return err
+						}
+						if flags["--json"] {
+							return errors.Join(err, printJSON(preview))
+						}
+						printSyncPreview(preview, flags["--dry-run"])
+						return err
+					},
+				},
+				{
+					Name: "clean",
+					Description: "Quarantine the compatdata and shadercache of the given appids, or of every " +
+						"uninstalled game with --uninstalled.\n\tGames with saves that aren't in Steam Cloud are " +
+						"only cleaned with --local-saves.",
+					ExecFunc: func(ctx context.Context, args []string) error {
+						flags, appIDs := parseFlags(args, "--json", "--uninstalled", "--local-saves")
+						if flags["--uninstalled"] == (len(appIDs) != 0) {
+							return fmt.Errorf("%w: expected either --uninstalled or appids", internal.ErrInvalidArgument)
+						}
+						var cleaned []string
+						err := internal.WithOperationLock(internal.OperationGameData, func() error {
+							var err error
+							cleaned, err = internal.CleanGames(ctx, appIDs, flags["--uninstalled"], flags["--local-saves"])
+							return err
+						})
+						if flags["--json"] {
+							return errors.Join(err, printJSON(cleaned))
+						}
+						for _, appID := range cleaned {
+							fmt.Println("Cleaned", appID)
+						}
+						return err
+					},
+				},
+			},
+		},
+		{
+			Name: "move",
+			Description: "Move the data of the given games to a drive, e.g. 'move 620 1091500 --to ssd'.\n\t" +
+				"--to accepts 'ssd', a mount path or a card's label, --data accepts 'both', 'compatdata' or " +
+				"'shadercache'.",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				appIDs, to, dataType, err := parseMoveArgs(args)
+				if err != nil {
+					return err
+				}
+				drive, err := internal.ResolveDrive(to)
+				if err != nil {
+					return err
+				}
+				err = internal.WithOperationLock(internal.OperationGameData, func() error {
+					return internal.MoveGames(ctx, appIDs, drive, dataType, func(progress internal.CopyProgress) {
+						fmt.Printf("\r%-80s", progress.String())
+					})
+				})
+				fmt.Println()
+				return err
+			},
+		},
+		{
+			Name:        "quarantine",
+			Description: "List, restore or delete the game data set aside by 'games clean'.",
+			Subcommands: []acmd.Command{
+				{
+					Name:        "list",
+					Description: "List the quarantined data on every attached drive, oldest first.",
+					ExecFunc: func(_ context.Context, args []string) error {
+						flags, rest := parseFlags(args, "--json")
+						if len(rest) != 0 {
+							return fmt.Errorf("%w: unexpected %q", internal.ErrInvalidArgument, rest[0])
+						}
+						quarantined, err := internal.ListQuarantine()
+						if err != nil {
+							return err
+						}
+						if flags["--json"] {
+							return printJSON(quarantined)
+						}
+						days, now := internal.GetQuarantineDays(), time.Now()
+						w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+						fmt.Fprintln(w, "APPID\tSOURCE\tQUARANTINED\tSIZE\tEXPIRED")
+						for _, data := range quarantined {
+							fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", data.AppID, data.Source,
+								data.QuarantinedAt.Format(time.DateTime), internal.GetHumanByteSize(data.Size),
+								data.Expired(days, now))
+						}
+						return w.Flush()
+					},
+				},
+				{
+					Name:        "restore",
+					Description: "Put the quarantined data of the given appids back where it was.",
+					ExecFunc: func(ctx context.Context, args []string) error {
+						if len(args) == 0 {
+							return fmt.Errorf("%w: expected appids", internal.ErrInvalidArgument)
+						}
+						var restored []internal.QuarantinedData
+						err := internal.WithOperationLock(internal.OperationGameData, func() error {
+							var err error
+							restored, err = internal.RestoreQuarantine(ctx, args)
+							return err
+						})
+						for _, data := range restored {
+							fmt.Println("Restored", data.Source)
+						}
+						return err
+					},
+				},
+				{
+					Name: "empty",
+					Description: "Delete all quarantined data for good, or only what's older than the retention " +
+						"period with --expired.",
+					ExecFunc: func(ctx context.Context, args []string) error {
+						flags, rest := parseFlags(args, "--expired")
+						if len(rest) != 0 {
+							return fmt.Errorf("%w: unexpected %q", internal.ErrInvalidArgument, rest[0])
+						}
+						var freed int64
+						err := internal.WithOperationLock(internal.OperationGameData, func() error {
+							var err error
+							freed, err = internal.EmptyQuarantine(ctx, flags["--expired"])
+							return err
+						})
+						fmt.Println("Freed", internal.GetHumanByteSize(freed))
+						return err
+					},
+				},
+				{
+					Name: "days",
+					Description: "Set how many days quarantined data is kept before it's deleted, 0 keeps it " +
+						"until emptied.\n\tPrints the current value if none is given.",
+					ExecFunc: func(_ context.Context, args []string) error {
+						if len(args) == 0 {
+							fmt.Println(internal.GetQuarantineDays())
+							return nil
+						}
+						arg, err := singleArg(args)
+						if err != nil {
+							return err
+						}
+						days, err := strconv.Atoi(arg)
+						if err != nil {
+							return fmt.Errorf("%w: %q is not a number of days", internal.ErrInvalidArgument, arg)
+						}
+						return internal.SetQuarantineDays(days)
+					},
+				},
+			},
+		},
+		{
+			Name:        "backup",
+			Description: "Back up the saves and settings in the prefixes of the given appids.",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				if len(args) == 0 {
+					return fmt.Errorf("%w: expected appids", internal.ErrInvalidArgument)
+				}
+				return internal.WithOperationLock(internal.OperationGameData, func() error {
+					for _, appID := range args {
+						manifest, err := internal.BackupGame(ctx, appID)
+						if err != nil {
+							return err
+						}
+						if manifest == nil {
+							fmt.Println("Nothing to back up for", appID)
+							continue
+						}
+						fmt.Println("Backed up", appID, "to", manifest.Archive)
+					}
+					return nil
+				})
+			},
+		},
+		{
+			Name: "restore",
+			Description: "Restore the saves of a game from its newest backup, e.g. 'restore 620'.\n\t" +
+				"--from restores a specific backup archive instead.",
+			ExecFunc: func(ctx context.Context, args []string) error {
+				values, rest, err := parseValueFlags(args, "--from")
+				if err != nil {
+					return err
+				}
+				appID, err := singleArg(rest)
+				if err != nil {
+					return err
+				}
+				return internal.WithOperationLock(internal.OperationGameData, func() error {
+					manifest, err := internal.RestoreBackup(ctx, appID, values["--from"])
+					if err != nil {
+						return err
+					}
+					fmt.Println("Restored", manifest.Files, "files from", manifest.Archive)
+					return nil
+				})
+			},
+		},
+		{
+			Name:        "backups",
+			Description: "List every save game backup, newest first.",
+			ExecFunc: func(_ context.Context, args []string) error {
+				flags, rest := parseFlags(args, "--json")
+				if len(rest) != 0 {
+					return fmt.Errorf("%w: unexpected %q", internal.ErrInvalidArgument, rest[0])
+				}
+				backups, err := internal.ListBackups()
+				if err != nil {
+					return err
+				}
+				if flags["--json"] {
+					return printJSON(backups)
+				}
+				w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "APPID\tNAME\tCREATED\tFILES\tSIZE\tARCHIVE")
+				for _, backup := range backups {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", backup.AppID, backup.Name,
+						backup.CreatedAt.Format(time.DateTime), backup.Files, internal.GetHumanByteSize(backup.Size),
+						backup.Archive)
+				}
+				return w.Flush()
+			},
+		},
 		{
 			Name:        "recommended",
 			Description: "Set all values to Cryo's recommendations.",
-			ExecFunc: func(context.Context, []string) error {
-				err := internal.UseRecommendedSettings()
+			ExecFunc: func(ctx context.Context, _ []string) error {
+				err := internal.WithOperationLock(internal.OperationSwap, func() error {
+					return internal.UseRecommendedSettings(ctx)
+				})
 				if err != nil {
 					return err
 				}
@@ -220,8 +710,10 @@ func main() {
 		{
 			Name:        "stock",
 			Description: "Set all values to Valve defaults.",
-			ExecFunc: func(context.Context, []string) error {
-				err := internal.UseStockSettings()
+			ExecFunc: func(ctx context.Context, _ []string) error {
+				err := internal.WithOperationLock(internal.OperationSwap, func() error {
+					return internal.UseStockSettings(ctx)
+				})
 				if err != nil {
 					return err
 				}
@@ -230,11 +722,6 @@ func main() {
 		},
 	}
 
-	// If no args are passed, assume "gui"
-	if len(os.Args) <= 1 {
-		os.Args = []string{"", "gui"}
-	}
-
 	// Basic program metadata
 	r := acmd.RunnerOf(cmds, acmd.Config{
 		AppName:         "cryoutilities",
@@ -243,9 +730,163 @@ func main() {
 		Version:         internal.CurrentVersionNumber,
 	})
 
-	// Run the command parser
+	// Run the command parser, exit codes are documented in the README
 	if err := r.Run(); err != nil {
 		internal.CryoUtils.ErrorLog.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		if errors.Is(err, acmd.ErrNoArgs) {
+			os.Exit(internal.ExitInvalidArgument)
+		}
+		os.Exit(internal.ExitCode(err))
+	}
+}
+
+// Repair every broken link, or only those of the given appids.
+func repairLinks(repair string, appIDs []string) error {
+	links, err := internal.ScanDataLinks()
+	if err != nil {
+		return err
+	}
+	selected := make(map[string]bool)
+	for _, appID := range appIDs {
+		selected[appID] = true
+	}
+	var errs []error
+	for _, link := range links {
+		if !link.IsBroken() || (len(selected) != 0 && !selected[link.AppID]) {
+			continue
+		}
+		if repair == internal.LinkRepairRepoint && link.Candidate == "" {
+			fmt.Println("Skipping", link.Path+", no copy was found on the attached drives")
+			continue
+		}
+		err = internal.RepairDataLink(link, repair)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Println("Repaired", link.Path)
+	}
+	return errors.Join(errs...)
+}
+
+// Separate the given flags from the rest of the arguments.
+func parseFlags(args []string, known ...string) (flags map[string]bool, rest []string) {
+	flags = make(map[string]bool)
+	for _, arg := range args {
+		if internal.Contains(known, arg) {
+			flags[arg] = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	return flags, rest
+}
+
+// Look game names up in the Steam API again if asked to, rather than using the cached ones.
+func refreshAppNames(ctx context.Context, refresh bool) error {
+	if !refresh {
+		return nil
+	}
+	_, err := internal.RefreshAppNames(ctx)
+	return err
+}
+
+// Separate flags that take a value, like --format csv, from the rest of the arguments.
+func parseValueFlags(args []string, known ...string) (values map[string]string, rest []string, err error) {
+	values = make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		if !internal.Contains(known, args[i]) {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 == len(args) {
+			return nil, nil, fmt.Errorf("%w: %s needs a value", internal.ErrInvalidArgument, args[i])
+		}
+		values[args[i]] = args[i+1]
+		i++
+	}
+	return values, rest, nil
+}
+
+// Print a value as indented JSON.
+func printJSON(v any) error {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(output))
+	return nil
+}
+
+// Print the games a sync moved, or would move on a dry run, and what it does to each drive's space.
+func printSyncPreview(preview *internal.SyncPreview, dryRun bool) {
+	verb := "Moved"
+	if dryRun {
+		verb = "Would move"
+	} else if len(preview.ToFrom)+len(preview.ToTo) != 0 {
+		// End the progress line
+		fmt.Println()
+	}
+	for _, direction := range []struct {
+		games map[string]string
+		to    string
+	}{
+		{preview.ToFrom, preview.From},
+		{preview.ToTo, preview.To},
+	} {
+		var games []string
+		for game := range direction.games {
+			games = append(games, game)
+		}
+		sort.Strings(games)
+		for _, game := range games {
+			fmt.Printf("%s %s to %s (%s)\n", verb, game, direction.to, direction.games[game])
+		}
+	}
+	if len(preview.ToFrom)+len(preview.ToTo) == 0 {
+		fmt.Println("Nothing to move, everything is synced.")
+	}
+	for _, space := range preview.Spaces {
+		fmt.Println(space)
+		if len(space.Trimmed) != 0 {
+			fmt.Println("  Not enough space, leaving these where they are:", strings.Join(space.Trimmed, ", "))
+		}
+	}
+}
+
+// Split the arguments of the move command into appids, the destination and the kind of data to move.
+func parseMoveArgs(args []string) (appIDs []string, to string, dataType string, err error) {
+	dataType = internal.DataTypeBoth
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--to", "--data":
+			if i+1 == len(args) {
+				return nil, "", "", fmt.Errorf("%w: %s needs a value", internal.ErrInvalidArgument, args[i])
+			}
+			if args[i] == "--to" {
+				to = args[i+1]
+			} else {
+				dataType = strings.ToLower(args[i+1])
+			}
+			i++
+		default:
+			if _, err := strconv.Atoi(args[i]); err != nil {
+				return nil, "", "", fmt.Errorf("%w: %q is not an appid", internal.ErrInvalidArgument, args[i])
+			}
+			appIDs = append(appIDs, args[i])
+		}
+	}
+	if len(appIDs) == 0 || to == "" {
+		return nil, "", "", fmt.Errorf("%w: expected appids and --to <drive>", internal.ErrInvalidArgument)
+	}
+	return appIDs, to, dataType, nil
+}
+
+// Get the single argument a command expects.
+func singleArg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("%w: expected exactly one argument, got %d", internal.ErrInvalidArgument, len(args))
 	}
+	return args[0], nil
 }
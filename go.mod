@@ -5,9 +5,10 @@ go 1.20
 require (
 	fyne.io/fyne/v2 v2.3.1
 	github.com/andygrunwald/vdf v1.1.0
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/cristalhq/acmd v0.11.0
+	github.com/klauspost/compress v1.16.7
 	github.com/moby/sys/mountinfo v0.6.2
-	github.com/otiai10/copy v1.9.0
 	golang.org/x/sys v0.5.0
 )
 
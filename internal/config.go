@@ -20,6 +20,7 @@ import (
 	"image/color"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // CurrentVersionNumber Version number to build with, Fyne can't support build flags just yet.
@@ -34,6 +35,31 @@ var InstallDirectory = filepath.Join(HomeDirectory, ".cryo_utilities")
 // LogFilePath Location of the log file
 var LogFilePath = filepath.Join(InstallDirectory, "cryoutilities.log")
 
+// OperationLockPath Location of the lock file shared by every running copy of CryoUtilities. It's kept in the home
+// of the user who ran sudo, so the GUI and the CLI run with sudo lock the same file.
+var OperationLockPath = filepath.Join(getInvokingUserHome(), ".cryo_utilities", "cryoutilities.lock")
+
+// InstanceSocketPath Per-user socket a second GUI launch uses to reach the running one
+var InstanceSocketPath = getInstanceSocketPath()
+
+// MoveJournalPath Location of the journal that tracks each step of game data moves
+var MoveJournalPath = filepath.Join(InstallDirectory, "move_journal.json")
+
+// DriveRegistryPath Location of the list of known external drives and where they were last mounted
+var DriveRegistryPath = filepath.Join(InstallDirectory, "drives.json")
+
+// SettingsPath Location of the settings shared by the GUI and the CLI
+var SettingsPath = filepath.Join(InstallDirectory, "settings.json")
+
+// CrashReportDirectory Location crash reports are written to
+var CrashReportDirectory = filepath.Join(InstallDirectory, "crash_reports")
+
+// PanicOutputPath Location the GUI sends stderr to, so panics outside the handler still leave a trace
+var PanicOutputPath = filepath.Join(InstallDirectory, "cryoutilities.stderr")
+
+// RecentLogLines Number of log lines kept in memory for crash reports
+var RecentLogLines = 100
+
 //////////////////////////
 // Recommended Settings //
 //////////////////////////
@@ -104,6 +130,12 @@ var Red = color.RGBA{R: 255, G: 0, B: 0, A: 255}
 // White UI Color
 var White = color.RGBA{R: 255, G: 255, B: 255, A: 255}
 
+// LockPollInterval How often the GUI checks whether another process holds the operation lock
+var LockPollInterval = 2 * time.Second
+
+// DrivePollInterval How often the GUI checks whether a drive was mounted somewhere new
+var DrivePollInterval = 5 * time.Second
+
 //////////////////////////////////
 // Swap and swappiness settings //
 //////////////////////////////////
@@ -130,6 +162,12 @@ var LibraryVDFLocation = filepath.Join(HomeDirectory, ".steam/steam/steamapps/li
 // MountDirectory The folder where all external devices are mounts
 var MountDirectory = "/run/media"
 
+// DiskByUUIDDirectory The folder udev keeps links to each filesystem by UUID in
+var DiskByUUIDDirectory = "/dev/disk/by-uuid"
+
+// DiskByLabelDirectory The folder udev keeps links to each filesystem by label in
+var DiskByLabelDirectory = "/dev/disk/by-label"
+
 // SteamDataRoot The default location where Steam keeps compatdata and shadercache
 var SteamDataRoot = filepath.Join(HomeDirectory, ".local/share/Steam")
 
@@ -139,6 +177,12 @@ var SteamCompatRoot = filepath.Join(SteamDataRoot, "steamapps/compatdata")
 // SteamShaderRoot Generates the full path of the shadercache folder, on SSD
 var SteamShaderRoot = filepath.Join(SteamDataRoot, "steamapps/shadercache")
 
+// UserDataDirectory The folder Steam keeps each user's settings in, including their non-Steam shortcuts
+var UserDataDirectory = filepath.Join(SteamDataRoot, "userdata")
+
+// AppInfoPath The location of Steam's cache of what it knows about every app it has seen
+var AppInfoPath = filepath.Join(SteamDataRoot, "appcache/appinfo.vdf")
+
 // ExternalDataRoot The location where I'll keep compatdata and shadercache on microSD cards
 var ExternalDataRoot = "cryoutilities_steam_data"
 
@@ -148,9 +192,75 @@ var ExternalCompatRoot = filepath.Join(ExternalDataRoot, "compatdata")
 // ExternalShaderRoot Generates the full path of the shadercache folder, on microSD
 var ExternalShaderRoot = filepath.Join(ExternalDataRoot, "shadercache")
 
+// QuarantineDirectoryName The folder next to each drive's compatdata and shadercache that cleaned up data is kept in
+var QuarantineDirectoryName = "cryoutilities_quarantine"
+
+// DefaultQuarantineDays How long cleaned up data is kept before it's deleted for good, 0 keeps it until emptied
+var DefaultQuarantineDays = 14
+
+// BackupDirectory Location save game backups are kept in
+var BackupDirectory = filepath.Join(InstallDirectory, "backups")
+
+// BackupPaths The parts of a game's prefix that hold its saves and settings, backed up before it's cleaned up
+var BackupPaths = []string{"pfx/drive_c/users/steamuser", "pfx/drive_c/users/Public/Documents"}
+
+// BackupExcludes Parts of BackupPaths that only hold temporary files, left out of backups
+var BackupExcludes = []string{"pfx/drive_c/users/steamuser/AppData/Local/Temp", "pfx/drive_c/users/steamuser/Temp"}
+
+// SaveLocations The parts of a game's prefix where Windows games keep their saves
+var SaveLocations = []string{
+	"pfx/drive_c/users/steamuser/Documents",
+	"pfx/drive_c/users/steamuser/Saved Games",
+	"pfx/drive_c/users/steamuser/AppData/Roaming",
+	"pfx/drive_c/users/steamuser/AppData/Local",
+	"pfx/drive_c/users/steamuser/AppData/LocalLow",
+	"pfx/drive_c/users/Public/Documents",
+}
+
+// SaveLocationExcludes Folders in SaveLocations that every new prefix comes with, or that only hold shader and crash
+// caches, which never hold saves
+var SaveLocationExcludes = []string{"Microsoft", "Temp", "D3DSCache", "CrashDumps", "CrashReportClient", "UnrealEngine",
+	"NVIDIA", "NVIDIA Corporation", "AMD"}
+
+// BackupsKept Number of backups kept for each game, older ones are deleted when a new one is made
+var BackupsKept = 3
+
 // SteamApiUrl The URL for the Steam GetAppList URL
 var SteamApiUrl = "https://api.steampowered.com/ISteamApps/GetAppList/v0002/"
 
-// SteamGameMaxInteger Anything over this number is presumed to be a Proton version
+// AppNameCachePath Location game names from the Steam API are kept between runs
+var AppNameCachePath = filepath.Join(InstallDirectory, "app_names.json")
+
+// AppNameCacheTTL How long game names are kept before they're looked up in the Steam API again
+var AppNameCacheTTL = 7 * 24 * time.Hour
+
+// SteamAPITimeout How long to wait for the Steam API before continuing without game names
+var SteamAPITimeout = 30 * time.Second
+
+// SizeCachePath Location directory sizes are kept between runs, so unchanged data isn't measured again
+var SizeCachePath = filepath.Join(InstallDirectory, "sizes.json")
+
+// SizeWorkers Number of directories read at once when measuring game data
+var SizeWorkers = 8
+
+// DeletionTimeout How long to wait for a deleted directory to disappear before giving up
+var DeletionTimeout = 5 * time.Minute
+
+// CopyWorkers Number of files copied at once when moving game data
+var CopyWorkers = 4
+
+// CopyBufferSize Size of the chunks files are copied in, progress is reported after each one
+var CopyBufferSize = 1024 * 1024
+
+// CopyProgressInterval How often copy progress is reported
+var CopyProgressInterval = 250 * time.Millisecond
+
+// DefaultVerifyMode How copied game data is checked before the source is deleted, see VerifyModes
+var DefaultVerifyMode = VerifyMetadata
+
+// VerificationReportLimit Number of differing files listed in a verification error, the log gets all of them
+var VerificationReportLimit = 10
+
+// SteamGameMaxInteger Anything over this number is presumed to be a Proton version, unless it's a non-Steam shortcut
 // Prevents accidental removal of Proton files
 var SteamGameMaxInteger = 1000000000
@@ -0,0 +1,183 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Sentinel errors, check for these with errors.Is.
+var (
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrAuth               = errors.New("sudo authentication failed")
+	ErrInsufficientSpace  = errors.New("not enough free space")
+	ErrSwapBusy           = errors.New("swap is in use and can't be disabled")
+	ErrNoSwapFile         = errors.New("no swapfile found")
+	ErrSteamRunning       = errors.New("game is running in Steam")
+	ErrKernelRejected     = errors.New("the kernel rejected the value")
+	ErrOperationLocked    = errors.New("another operation is already running")
+	ErrVerificationFailed = errors.New("copied data doesn't match the source")
+	ErrLocalSaves         = errors.New("game has saves that aren't in Steam Cloud")
+)
+
+// Exit codes for the CLI, documented in the README. Keep them stable, scripts rely on them.
+const (
+	ExitOK                 = 0
+	ExitFailure            = 1
+	ExitInvalidArgument    = 2
+	ExitAuth               = 3
+	ExitInsufficientSpace  = 4
+	ExitSwapBusy           = 5
+	ExitSteamRunning       = 6
+	ExitKernelRejected     = 7
+	ExitOperationLocked    = 8
+	ExitVerificationFailed = 9
+	ExitLocalSaves         = 10
+	ExitCancelled          = 130
+)
+
+// CommandError An external command that failed, along with what it printed to stderr
+type CommandError struct {
+	Command string
+	Stderr  string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s: %v", e.Command, e.Err)
+	}
+	return fmt.Sprintf("%s: %v: %s", e.Command, e.Err, e.Stderr)
+}
+
+// Unwrap Also matches ErrAuth when sudo refused the password.
+func (e *CommandError) Unwrap() []error {
+	if isSudoAuthFailure(e.Stderr) {
+		return []error{ErrAuth, e.Err}
+	}
+	return []error{e.Err}
+}
+
+// LockHeldError Returned when the operation lock belongs to another operation
+type LockHeldError struct {
+	Operation string
+	Holder    *LockHolder
+}
+
+func (e *LockHeldError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("cannot start %s, another operation is already running", e.Operation)
+	}
+	return fmt.Sprintf("cannot start %s, a %s is already running (PID %d)",
+		e.Operation, e.Holder.Operation, e.Holder.PID)
+}
+
+func (e *LockHeldError) Is(target error) bool {
+	return target == ErrOperationLocked
+}
+
+// Check sudo's stderr for the messages it prints when the password is wrong or missing.
+func isSudoAuthFailure(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	return strings.Contains(stderr, "incorrect password") ||
+		strings.Contains(stderr, "a password is required") ||
+		strings.Contains(stderr, "no password was provided") ||
+		strings.Contains(stderr, "a terminal is required")
+}
+
+// Run a command and return its output, keeping stderr in the error if it fails.
+func runCommand(cmd *exec.Cmd) ([]byte, error) {
+	out, err := cmd.Output()
+	if err != nil {
+		cmdErr := &CommandError{Command: strings.Join(cmd.Args, " "), Err: err}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			cmdErr.Stderr = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		CryoUtils.ErrorLog.Println(cmdErr)
+		return out, cmdErr
+	}
+	return out, nil
+}
+
+// ExitCode Get the CLI exit code that matches an error.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, context.Canceled):
+		return ExitCancelled
+	case errors.Is(err, ErrInvalidArgument):
+		return ExitInvalidArgument
+	case errors.Is(err, ErrAuth):
+		return ExitAuth
+	case errors.Is(err, ErrInsufficientSpace):
+		return ExitInsufficientSpace
+	case errors.Is(err, ErrSwapBusy):
+		return ExitSwapBusy
+	case errors.Is(err, ErrSteamRunning):
+		return ExitSteamRunning
+	case errors.Is(err, ErrKernelRejected):
+		return ExitKernelRejected
+	case errors.Is(err, ErrOperationLocked):
+		return ExitOperationLocked
+	case errors.Is(err, ErrVerificationFailed):
+		return ExitVerificationFailed
+	case errors.Is(err, ErrLocalSaves):
+		return ExitLocalSaves
+	default:
+		return ExitFailure
+	}
+}
+
+// Get a message for an error that a user can act on, falling back to the error itself.
+func friendlyErrorMessage(err error) string {
+	var message string
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "The operation was cancelled."
+	case errors.Is(err, ErrOperationLocked):
+		return err.Error() + "\nPlease wait for it to finish and try again."
+	case errors.Is(err, ErrAuth):
+		message = "Your sudo password was rejected. Please restart CryoUtilities and enter the correct password."
+	case errors.Is(err, ErrInsufficientSpace):
+		message = "There isn't enough free space on the destination drive.\n" +
+			"Please free up some space, choose a smaller size or move fewer games."
+	case errors.Is(err, ErrSwapBusy):
+		message = "Swap couldn't be turned off because it's in use.\n" +
+			"Please close some programs, or reboot, and try again."
+	case errors.Is(err, ErrSteamRunning):
+		message = "A game whose data would change is running. Please close it and try again."
+	case errors.Is(err, ErrKernelRejected):
+		message = "The kernel refused this setting, it may not be supported on this system."
+	case errors.Is(err, ErrVerificationFailed):
+		message = "The copied game data didn't match the original, so the original was kept.\n" +
+			"The destination drive may be failing, please check it and try again."
+	case errors.Is(err, ErrLocalSaves):
+		message = "Some of these games have saves that aren't in Steam Cloud.\n" +
+			"Please confirm that they can be cleaned up anyway."
+	case errors.Is(err, ErrNoSwapFile):
+		message = "No swap file was found. Swap partitions aren't supported."
+	default:
+		return err.Error()
+	}
+	return message + "\n\nDetails: " + err.Error()
+}
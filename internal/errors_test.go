@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	type args struct {
+		err error
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "No error",
+			args: args{
+				err: nil,
+			},
+			want: ExitOK,
+		},
+		{
+			name: "Unknown error",
+			args: args{
+				err: errors.New("something broke"),
+			},
+			want: ExitFailure,
+		},
+		{
+			name: "Wrapped sentinel",
+			args: args{
+				err: fmt.Errorf("error resizing /home/swapfile: %w", ErrInsufficientSpace),
+			},
+			want: ExitInsufficientSpace,
+		},
+		{
+			name: "Sudo rejected the password",
+			args: args{
+				err: fmt.Errorf("error disabling swap: %w", &CommandError{
+					Command: "sudo swapoff -a",
+					Stderr:  "sudo: 1 incorrect password attempt",
+					Err:     errors.New("exit status 1"),
+				}),
+			},
+			want: ExitAuth,
+		},
+		{
+			name: "Lock held by another process",
+			args: args{
+				err: &LockHeldError{Operation: OperationSwap, Holder: &LockHolder{PID: 42, Operation: OperationGameData}},
+			},
+			want: ExitOperationLocked,
+		},
+		{
+			name: "Cancelled",
+			args: args{
+				err: fmt.Errorf("error copying data for 123: %w", context.Canceled),
+			},
+			want: ExitCancelled,
+		},
+		{
+			name: "Saves not in Steam Cloud",
+			args: args{
+				err: fmt.Errorf("%w: 620, 400", ErrLocalSaves),
+			},
+			want: ExitLocalSaves,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.args.err); got != tt.want {
+				t.Errorf("ExitCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
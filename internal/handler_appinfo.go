@@ -0,0 +1,287 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Versions of appinfo.vdf, from the magic number at the start of the file.
+const (
+	appInfoV27 = 0x07564427
+	appInfoV28 = 0x07564428
+	// Keys are stored once in a table at the end of the file, and referred to by index
+	appInfoV29 = 0x07564429
+)
+
+// Types of value in Steam's binary KeyValues format.
+const (
+	kvTypeMap     = 0x00
+	kvTypeString  = 0x01
+	kvTypeInt32   = 0x02
+	kvTypeFloat32 = 0x03
+	kvTypePointer = 0x04
+	kvTypeColor   = 0x06
+	kvTypeUint64  = 0x07
+	kvTypeEnd     = 0x08
+	kvTypeInt64   = 0x0A
+)
+
+// AppInfo What Steam knows about an app it has seen, from appcache/appinfo.vdf.
+type AppInfo struct {
+	AppID  int
+	Name   string
+	Type   string
+	OSList []string
+}
+
+// IsTool Whether the app is a tool, like Proton or the Steam Linux Runtime, rather than a game.
+func (a AppInfo) IsTool() bool {
+	return strings.EqualFold(a.Type, "tool")
+}
+
+// Whether an appid belongs to a tool like Proton, whose files must never be moved or cleaned as game data.
+func isToolApp(appID int) bool {
+	info, ok := getAppInfo(appID)
+	return ok && info.IsTool()
+}
+
+// The apps read from appinfo.vdf, read once per run.
+var appInfoCache = struct {
+	sync.Once
+	apps map[int]AppInfo
+}{}
+
+// Get what Steam knows about a local app, even if it was uninstalled and its manifest is gone.
+func getAppInfo(appID int) (AppInfo, bool) {
+	appInfoCache.Do(func() {
+		apps, err := readAppInfo(AppInfoPath, getLocalAppIDs())
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			CryoUtils.ErrorLog.Println(err)
+		}
+		appInfoCache.apps = apps
+	})
+	info, ok := appInfoCache.apps[appID]
+	return info, ok
+}
+
+// Read the apps in a binary appinfo.vdf, keeping only the wanted appids, or every app if wanted is nil.
+func readAppInfo(path string, wanted map[int]bool) (map[int]AppInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	var header struct {
+		Magic    uint32
+		Universe uint32
+	}
+	err = binary.Read(r, binary.LittleEndian, &header)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	// After its appid and size, each entry has its state, update time, token, hash and change number
+	entryHeader := 4 + 4 + 8 + 20 + 4
+	var keys []string
+	switch header.Magic {
+	case appInfoV27:
+	case appInfoV28:
+		// and a hash of its KeyValues
+		entryHeader += 20
+	case appInfoV29:
+		entryHeader += 20
+		var tableOffset int64
+		err = binary.Read(r, binary.LittleEndian, &tableOffset)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		keys, err = readKeyTable(io.NewSectionReader(f, tableOffset, math.MaxInt64-tableOffset))
+		if err != nil {
+			return nil, fmt.Errorf("error reading the key table of %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s has an unsupported format %#x", path, header.Magic)
+	}
+
+	apps := make(map[int]AppInfo)
+	for {
+		var appID, size uint32
+		err = binary.Read(r, binary.LittleEndian, &appID)
+		if err != nil {
+			return apps, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		// The list ends with an appid of 0
+		if appID == 0 {
+			return apps, nil
+		}
+		err = binary.Read(r, binary.LittleEndian, &size)
+		if err != nil {
+			return apps, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		if wanted != nil && !wanted[int(appID)] {
+			_, err = r.Discard(int(size))
+			if err != nil {
+				return apps, fmt.Errorf("error reading %s: %w", path, err)
+			}
+			continue
+		}
+
+		data := make([]byte, size)
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return apps, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		if len(data) < entryHeader {
+			return apps, fmt.Errorf("error reading %s: entry for %d is too short", path, appID)
+		}
+		kv := &kvReader{r: bytes.NewReader(data[entryHeader:]), keys: keys}
+		values, err := kv.readMap()
+		if err != nil {
+			return apps, fmt.Errorf("error reading %s: entry for %d: %w", path, appID, err)
+		}
+		apps[int(appID)] = parseAppInfo(int(appID), values)
+	}
+}
+
+// Pick the name, type and supported operating systems out of an app's KeyValues.
+func parseAppInfo(appID int, values map[string]interface{}) AppInfo {
+	info := AppInfo{AppID: appID}
+	appInfo, _ := getVDFValue(values, "appinfo").(map[string]interface{})
+	common, _ := getVDFValue(appInfo, "common").(map[string]interface{})
+	info.Name, _ = getVDFValue(common, "name").(string)
+	info.Type, _ = getVDFValue(common, "type").(string)
+	osList, _ := getVDFValue(common, "oslist").(string)
+	for _, system := range strings.Split(osList, ",") {
+		if system = strings.TrimSpace(system); system != "" {
+			info.OSList = append(info.OSList, system)
+		}
+	}
+	return info
+}
+
+// Read the table of keys at the end of a v29 appinfo.vdf.
+func readKeyTable(r io.Reader) ([]string, error) {
+	br := bufio.NewReader(r)
+	var count uint32
+	err := binary.Read(br, binary.LittleEndian, &count)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for i := uint32(0); i < count; i++ {
+		key, err := br.ReadString(0)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, strings.TrimSuffix(key, "\x00"))
+	}
+	return keys, nil
+}
+
+// Reads Steam's binary KeyValues, as used by appinfo.vdf and shortcuts.vdf.
+type kvReader struct {
+	r *bytes.Reader
+	// The key table of a v29 appinfo.vdf, keys are inline strings when nil
+	keys []string
+}
+
+func (k *kvReader) readString() (string, error) {
+	var b strings.Builder
+	for {
+		c, err := k.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if c == 0 {
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+	}
+}
+
+func (k *kvReader) readKey() (string, error) {
+	if k.keys == nil {
+		return k.readString()
+	}
+	var index uint32
+	err := binary.Read(k.r, binary.LittleEndian, &index)
+	if err != nil {
+		return "", err
+	}
+	if int(index) >= len(k.keys) {
+		return "", fmt.Errorf("key %d is outside the key table", index)
+	}
+	return k.keys[index], nil
+}
+
+// Read the values of a map up to its end marker, nested maps are read whole.
+func (k *kvReader) readMap() (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for {
+		valueType, err := k.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if valueType == kvTypeEnd {
+			return values, nil
+		}
+		key, err := k.readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		switch valueType {
+		case kvTypeMap:
+			values[key], err = k.readMap()
+		case kvTypeString:
+			values[key], err = k.readString()
+		case kvTypeInt32, kvTypePointer, kvTypeColor:
+			var value int32
+			err = binary.Read(k.r, binary.LittleEndian, &value)
+			values[key] = value
+		case kvTypeFloat32:
+			var value float32
+			err = binary.Read(k.r, binary.LittleEndian, &value)
+			values[key] = value
+		case kvTypeUint64:
+			var value uint64
+			err = binary.Read(k.r, binary.LittleEndian, &value)
+			values[key] = value
+		case kvTypeInt64:
+			var value int64
+			err = binary.Read(k.r, binary.LittleEndian, &value)
+			values[key] = value
+		default:
+			return nil, fmt.Errorf("unknown value type %#x for %s", valueType, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
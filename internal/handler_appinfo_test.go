@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Craft an appinfo.vdf holding the given apps, in the format of the given version.
+func writeAppInfoFixture(t *testing.T, magic uint32, apps []AppInfo) string {
+	keys := []string{"appinfo", "appid", "common", "name", "type", "oslist"}
+	var buf bytes.Buffer
+	key := func(name string) {
+		if magic != appInfoV29 {
+			buf.WriteString(name + "\x00")
+			return
+		}
+		for i := range keys {
+			if keys[i] == name {
+				_ = binary.Write(&buf, binary.LittleEndian, uint32(i))
+			}
+		}
+	}
+
+	var file bytes.Buffer
+	_ = binary.Write(&file, binary.LittleEndian, []uint32{magic, 1})
+	if magic == appInfoV29 {
+		// Filled in once the entries are written
+		_ = binary.Write(&file, binary.LittleEndian, int64(0))
+	}
+	for _, app := range apps {
+		buf.Reset()
+		buf.Write(make([]byte, 4+4+8+20+4))
+		if magic != appInfoV27 {
+			buf.Write(make([]byte, 20))
+		}
+		buf.WriteByte(kvTypeMap)
+		key("appinfo")
+		buf.WriteByte(kvTypeInt32)
+		key("appid")
+		_ = binary.Write(&buf, binary.LittleEndian, int32(app.AppID))
+		buf.WriteByte(kvTypeMap)
+		key("common")
+		for _, value := range [][2]string{{"name", app.Name}, {"type", app.Type}, {"oslist", "windows,linux"}} {
+			buf.WriteByte(kvTypeString)
+			key(value[0])
+			buf.WriteString(value[1] + "\x00")
+		}
+		buf.Write([]byte{kvTypeEnd, kvTypeEnd, kvTypeEnd})
+
+		_ = binary.Write(&file, binary.LittleEndian, []uint32{uint32(app.AppID), uint32(buf.Len())})
+		file.Write(buf.Bytes())
+	}
+	_ = binary.Write(&file, binary.LittleEndian, uint32(0))
+
+	contents := file.Bytes()
+	if magic == appInfoV29 {
+		binary.LittleEndian.PutUint64(contents[8:], uint64(len(contents)))
+		_ = binary.Write(&file, binary.LittleEndian, uint32(len(keys)))
+		for _, k := range keys {
+			file.WriteString(k + "\x00")
+		}
+		contents = file.Bytes()
+	}
+
+	path := filepath.Join(t.TempDir(), "appinfo.vdf")
+	_ = os.WriteFile(path, contents, 0644)
+	return path
+}
+
+func TestReadAppInfo(t *testing.T) {
+	apps := []AppInfo{
+		{AppID: 620, Name: "Portal 2", Type: "Game"},
+		{AppID: 1493710, Name: "Proton Experimental", Type: "Tool"},
+		{AppID: 400, Name: "Portal", Type: "Game"},
+	}
+	want := map[int]AppInfo{
+		620:     {AppID: 620, Name: "Portal 2", Type: "Game", OSList: []string{"windows", "linux"}},
+		1493710: {AppID: 1493710, Name: "Proton Experimental", Type: "Tool", OSList: []string{"windows", "linux"}},
+	}
+
+	tests := []struct {
+		name  string
+		magic uint32
+	}{
+		{name: "v27", magic: appInfoV27},
+		{name: "v28", magic: appInfoV28},
+		{name: "v29 key table", magic: appInfoV29},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeAppInfoFixture(t, tt.magic, apps)
+			got, err := readAppInfo(path, map[int]bool{620: true, 1493710: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("readAppInfo() = %+v, want %+v", got, want)
+			}
+			if !got[1493710].IsTool() || got[620].IsTool() {
+				t.Error("IsTool() doesn't tell tools from games")
+			}
+		})
+	}
+
+	t.Run("Unsupported format", func(t *testing.T) {
+		path := writeAppInfoFixture(t, 0x07564426, apps)
+		if _, err := readAppInfo(path, nil); err == nil {
+			t.Error("readAppInfo() read an unsupported format")
+		}
+	})
+}
@@ -0,0 +1,463 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// The first entry of every backup archive, describing what's in it.
+const backupManifestName = "manifest.json"
+
+// BackupManifest What a save game backup holds, and where it was taken from.
+type BackupManifest struct {
+	AppID     string    `json:"appid"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// The prefix the backup was taken from, and is restored to if it still exists
+	Source string `json:"source"`
+	// The parts of the prefix that were backed up, relative to it
+	Paths []string `json:"paths"`
+	Files int      `json:"files"`
+	Size  int64    `json:"size"`
+	// The archive holding the backup, filled in when it's listed
+	Archive string `json:"archive"`
+}
+
+func (m BackupManifest) String() string {
+	return fmt.Sprintf("%s - %s - %s - %d files, %s", m.AppID, m.Name, m.CreatedAt.Format(time.DateTime), m.Files,
+		GetHumanByteSize(m.Size))
+}
+
+// A file or folder to add to a backup, relative to the prefix.
+type backupEntry struct {
+	path string
+	info fs.FileInfo
+}
+
+// Find the prefix of a game on any attached drive, following the SSD's link if its data was moved. Empty if it has
+// none.
+func findPrefix(appID string) (string, error) {
+	drives, err := getListOfAttachedDrives()
+	if err != nil {
+		return "", err
+	}
+	for _, drive := range drives {
+		compat, _ := getDataRoots(drive)
+		prefix := filepath.Join(compat, appID)
+		if info, err := os.Stat(prefix); err == nil && info.IsDir() {
+			return filepath.EvalSymlinks(prefix)
+		}
+	}
+	return "", nil
+}
+
+// Collect the saves and settings in a prefix, leaving out temporary files. Links are kept as links.
+func getBackupEntries(ctx context.Context, prefix string) ([]backupEntry, []string, error) {
+	var entries []backupEntry
+	var paths []string
+	for _, path := range BackupPaths {
+		root := filepath.Join(prefix, path)
+		if _, err := os.Lstat(root); os.IsNotExist(err) {
+			continue
+		}
+		paths = append(paths, path)
+		err := filepath.WalkDir(root, func(current string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			relative, err := filepath.Rel(prefix, current)
+			if err != nil {
+				return err
+			}
+			if Contains(BackupExcludes, relative) {
+				return filepath.SkipDir
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			entries = append(entries, backupEntry{path: relative, info: info})
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading %s: %w", root, err)
+		}
+	}
+	return entries, paths, nil
+}
+
+// BackupGame Archive the saves and settings in a game's prefix to BackupDirectory, keeping the last BackupsKept
+// backups of it. Returns nil if the game has no prefix, or nothing in it to back up.
+func BackupGame(ctx context.Context, appID string) (*BackupManifest, error) {
+	if !isGameDataDirectory(appID) {
+		return nil, fmt.Errorf("%w: %q is not a game's appid", ErrInvalidArgument, appID)
+	}
+	prefix, err := findPrefix(appID)
+	if err != nil || prefix == "" {
+		return nil, err
+	}
+	entries, paths, err := getBackupEntries(ctx, prefix)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+
+	manifest := &BackupManifest{AppID: appID, CreatedAt: time.Now(), Source: prefix, Paths: paths}
+	id, _ := strconv.Atoi(appID)
+	manifest.Name = getGameName(ctx, id)
+	for _, entry := range entries {
+		if entry.info.Mode().IsRegular() {
+			manifest.Files++
+			manifest.Size += entry.info.Size()
+		}
+	}
+
+	_ = os.MkdirAll(BackupDirectory, 0777)
+	manifest.Archive = filepath.Join(BackupDirectory,
+		fmt.Sprintf("%s-%s.tar.zst", appID, manifest.CreatedAt.Format("20060102-150405")))
+	// Written under another name first, so a cut off backup is never mistaken for a whole one
+	partial := manifest.Archive + ".partial"
+	err = writeBackupArchive(ctx, partial, prefix, manifest, entries)
+	if err == nil {
+		err = os.Rename(partial, manifest.Archive)
+	}
+	if err != nil {
+		_ = os.Remove(partial)
+		return nil, fmt.Errorf("error backing up %s: %w", appID, err)
+	}
+	CryoUtils.InfoLog.Println("Backed up", manifest.Files, "files from", prefix, "to", manifest.Archive)
+
+	pruneBackups(appID)
+	return manifest, nil
+}
+
+// Write the manifest, then every entry, to a new zstd compressed tar archive.
+func writeBackupArchive(ctx context.Context, path string, prefix string, manifest *BackupManifest,
+	entries []backupEntry) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder, err := zstd.NewWriter(file)
+	if err != nil {
+		return err
+	}
+	archive := tar.NewWriter(encoder)
+
+	contents, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = archive.WriteHeader(&tar.Header{Name: backupManifestName, Mode: 0644, Size: int64(len(contents)),
+		ModTime: manifest.CreatedAt, Typeflag: tar.TypeReg})
+	if err == nil {
+		_, err = archive.Write(contents)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		err = addBackupEntry(archive, prefix, entry)
+		if err != nil {
+			return err
+		}
+	}
+	err = archive.Close()
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	return err
+}
+
+// Add a single file, folder or link to a backup archive. Anything else, like sockets, is skipped.
+func addBackupEntry(archive *tar.Writer, prefix string, entry backupEntry) error {
+	source := filepath.Join(prefix, entry.path)
+	var link string
+	switch {
+	case entry.info.Mode()&os.ModeSymlink != 0:
+		var err error
+		link, err = os.Readlink(source)
+		if err != nil {
+			return err
+		}
+	case !entry.info.IsDir() && !entry.info.Mode().IsRegular():
+		CryoUtils.InfoLog.Println("Skipping special file", source)
+		return nil
+	}
+	header, err := tar.FileInfoHeader(entry.info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(entry.path)
+	err = archive.WriteHeader(header)
+	if err != nil || !entry.info.Mode().IsRegular() {
+		return err
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(archive, file)
+	return err
+}
+
+// Open a backup archive, returning a reader positioned after its manifest. The returned function closes it.
+func openBackupArchive(path string) (*BackupManifest, *tar.Reader, func(), error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	decoder, err := zstd.NewReader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, nil, err
+	}
+	closeArchive := func() {
+		decoder.Close()
+		_ = file.Close()
+	}
+
+	archive := tar.NewReader(decoder)
+	header, err := archive.Next()
+	if err == nil && header.Name != backupManifestName {
+		err = errors.New("the manifest is missing")
+	}
+	var manifest BackupManifest
+	if err == nil {
+		err = json.NewDecoder(archive).Decode(&manifest)
+	}
+	if err != nil {
+		closeArchive()
+		return nil, nil, nil, fmt.Errorf("error reading backup %s: %w", path, err)
+	}
+	manifest.Archive = path
+	return &manifest, archive, closeArchive, nil
+}
+
+// ListBackups Get every save game backup, newest first.
+func ListBackups() ([]BackupManifest, error) {
+	archives, err := filepath.Glob(filepath.Join(BackupDirectory, "*.tar.zst"))
+	if err != nil {
+		return nil, err
+	}
+	var backups []BackupManifest
+	for _, path := range archives {
+		manifest, _, closeArchive, err := openBackupArchive(path)
+		if err != nil {
+			CryoUtils.ErrorLog.Println("Skipping unreadable backup:", err)
+			continue
+		}
+		closeArchive()
+		backups = append(backups, *manifest)
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Delete all but the newest BackupsKept backups of a game.
+func pruneBackups(appID string) {
+	backups, err := ListBackups()
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		return
+	}
+	kept := 0
+	for _, backup := range backups {
+		if backup.AppID != appID {
+			continue
+		}
+		kept++
+		if kept <= BackupsKept {
+			continue
+		}
+		err = os.Remove(backup.Archive)
+		if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+			continue
+		}
+		CryoUtils.InfoLog.Println("Deleted old backup", backup.Archive)
+	}
+}
+
+// RestoreBackup Put the saves from a game's newest backup, or from the given archive, back into its prefix. Files in
+// the backup overwrite the ones in the prefix, anything else is left alone. If the prefix is gone, it's restored to
+// where it was, or to the SSD if that drive isn't attached. Returns the backup that was restored.
+func RestoreBackup(ctx context.Context, appID string, archive string) (*BackupManifest, error) {
+	if archive == "" {
+		backups, err := ListBackups()
+		if err != nil {
+			return nil, err
+		}
+		for _, backup := range backups {
+			if backup.AppID == appID {
+				archive = backup.Archive
+				break
+			}
+		}
+		if archive == "" {
+			return nil, fmt.Errorf("%w: no backups of %s found", ErrInvalidArgument, appID)
+		}
+	}
+	if isGameRunning(appID) {
+		return nil, fmt.Errorf("unable to restore %s: %w", appID, ErrSteamRunning)
+	}
+
+	manifest, reader, closeArchive, err := openBackupArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer closeArchive()
+	if manifest.AppID != appID {
+		return nil, fmt.Errorf("%w: %s is a backup of %s, not %s", ErrInvalidArgument, archive, manifest.AppID,
+			appID)
+	}
+
+	prefix, err := findPrefix(appID)
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "" {
+		prefix = filepath.Join(SteamCompatRoot, appID)
+		if _, err := os.Stat(filepath.Dir(manifest.Source)); err == nil {
+			prefix = manifest.Source
+		}
+	}
+	err = extractBackupArchive(ctx, reader, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("error restoring %s to %s: %w", archive, prefix, err)
+	}
+	CryoUtils.InfoLog.Println("Restored", archive, "to", prefix)
+	return manifest, nil
+}
+
+// Extract the rest of a backup archive into a prefix. Links are made last, so nothing is ever written through a link
+// from the archive.
+func extractBackupArchive(ctx context.Context, archive *tar.Reader, prefix string) error {
+	var links []*tar.Header
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		// Never write outside the prefix, whatever the archive says
+		if !filepath.IsLocal(header.Name) {
+			return fmt.Errorf("unsafe path %q in backup", header.Name)
+		}
+		target := filepath.Join(prefix, header.Name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, header.FileInfo().Mode().Perm()|0700)
+		case tar.TypeReg:
+			err = extractBackupFile(archive, header, target)
+		case tar.TypeSymlink:
+			links = append(links, header)
+		default:
+			CryoUtils.InfoLog.Println("Skipping", header.Name, "in backup")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, header := range links {
+		target := filepath.Join(prefix, header.Name)
+		if existing, err := os.Readlink(target); err == nil && existing == header.Linkname {
+			continue
+		}
+		_ = os.MkdirAll(filepath.Dir(target), 0755)
+		// Only an old link is replaced, never a folder or file
+		if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			_ = os.Remove(target)
+		}
+		err := os.Symlink(header.Linkname, target)
+		if err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write one file from a backup archive, keeping its permissions and modification time.
+func extractBackupFile(archive *tar.Reader, header *tar.Header, target string) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+	// A link in the way would have the file written wherever it points
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		_ = os.Remove(target)
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, header.FileInfo().Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, archive)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return os.Chtimes(target, header.ModTime, header.ModTime)
+}
+
+// Back up a game's saves before its data is cleaned up. Games without a prefix have nothing to lose.
+func backupBeforeCleanup(ctx context.Context, appID string) error {
+	manifest, err := BackupGame(ctx, appID)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		CryoUtils.InfoLog.Println("No saves to back up for", appID)
+	}
+	return nil
+}
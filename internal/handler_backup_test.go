@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupArchive(t *testing.T) {
+	prefix := t.TempDir()
+	steamuser := filepath.Join(prefix, "pfx", "drive_c", "users", "steamuser")
+	files := map[string]string{
+		"AppData/Roaming/Game/save1.sav": "first save",
+		"Documents/My Games/Game/config": "settings",
+		"AppData/Local/Temp/crash.dmp":   "temporary",
+	}
+	for name, contents := range files {
+		path := filepath.Join(steamuser, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("Documents", filepath.Join(steamuser, "My Documents")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, paths, err := getBackupEntries(context.Background(), prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || paths[0] != BackupPaths[0] {
+		t.Errorf("getBackupEntries() paths = %v, want only %s", paths, BackupPaths[0])
+	}
+
+	archive := filepath.Join(t.TempDir(), "620.tar.zst")
+	manifest := &BackupManifest{AppID: "620", CreatedAt: time.Now(), Source: prefix, Paths: paths, Files: 2}
+	if err := writeBackupArchive(context.Background(), archive, prefix, manifest, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	read, reader, closeArchive, err := openBackupArchive(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeArchive()
+	if read.AppID != "620" || read.Files != 2 || read.Archive != archive {
+		t.Errorf("openBackupArchive() manifest = %+v", read)
+	}
+	restored := t.TempDir()
+	if err := extractBackupArchive(context.Background(), reader, restored); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "AppData/Roaming/Game/save1.sav", want: "first save"},
+		{name: "Documents/My Games/Game/config", want: "settings"},
+		{name: "My Documents/My Games/Game/config", want: "settings"},
+		{name: "AppData/Local/Temp/crash.dmp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contents, err := os.ReadFile(filepath.Join(restored, BackupPaths[0], tt.name))
+			if tt.want == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("%s was restored, want it left out", tt.name)
+				}
+				return
+			}
+			if err != nil || string(contents) != tt.want {
+				t.Errorf("restored %s = %q, %v, want %q", tt.name, contents, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractBackupArchiveUnsafePath(t *testing.T) {
+	var buffer bytes.Buffer
+	archive := tar.NewWriter(&buffer)
+	contents := []byte("outside")
+	if err := archive.WriteHeader(&tar.Header{Name: "../escape", Mode: 0644, Size: int64(len(contents)),
+		Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = archive.Write(contents)
+	_ = archive.Close()
+
+	prefix := filepath.Join(t.TempDir(), "prefix")
+	err := extractBackupArchive(context.Background(), tar.NewReader(&buffer), prefix)
+	if err == nil {
+		t.Fatal("extractBackupArchive() accepted a path outside the prefix")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(prefix), "escape")); !os.IsNotExist(err) {
+		t.Errorf("extractBackupArchive() wrote outside the prefix")
+	}
+}
@@ -17,24 +17,52 @@
 package internal
 
 import (
+	"context"
 	"strconv"
 	"strings"
 )
 
+// Names of the presets, recorded so an interrupted preset can be applied again.
+const (
+	presetRecommended = "recommended"
+	presetStock       = "stock"
+)
+
 // ChangeSwapSizeCLI Change the swap file size to the specified size in GB
-func ChangeSwapSizeCLI(size int, isUI bool) error {
+// Cancelling is possible until the new file has been written, after that the resize runs to completion.
+func ChangeSwapSizeCLI(ctx context.Context, size int, isUI bool) error {
+	// Nothing has changed yet, so stopping here is free
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	// Refuse sizes that would fill /home, that can cause boot loops
+	err := checkSwapSpace(size)
+	if err != nil {
+		return err
+	}
+	previous := recordSwapResize(size)
 	// Refresh creds if running with UI
 	if isUI {
 		renewSudoAuth()
 	}
 	// Disable swap temporarily
-	err := disableSwap()
+	err = disableSwap()
 	if err != nil {
 		return err
 	}
 
 	// Resize the file
-	err = resizeSwapFile(size)
+	err = resizeSwapFile(ctx, size)
+	if ctx.Err() != nil {
+		if isUI {
+			renewSudoAuth()
+		}
+		restoreErr := restorePreviousSwapFile(previous)
+		if restoreErr != nil {
+			return restoreErr
+		}
+		return ctx.Err()
+	}
 	if err != nil {
 		return err
 	}
@@ -58,7 +86,9 @@ func ChangeSwapSizeCLI(size int, isUI bool) error {
 	return nil
 }
 
-func UseRecommendedSettings() error {
+// UseRecommendedSettings Apply every recommended setting, stopping between settings if cancelled.
+func UseRecommendedSettings(ctx context.Context) error {
+	recordOperationDetail("preset", presetRecommended)
 	// Change swap
 	CryoUtils.InfoLog.Println("Starting swap file resize...")
 	availableSpace, err := getFreeSpace("/home")
@@ -83,46 +113,64 @@ func UseRecommendedSettings() error {
 				size = 16
 			}
 		}
-		err = ChangeSwapSizeCLI(size, true)
+		err = ChangeSwapSizeCLI(ctx, size, true)
 		if err != nil {
 			return err
 		}
 	} else {
-		err = ChangeSwapSizeCLI(RecommendedSwapSize, true)
+		err = ChangeSwapSizeCLI(ctx, RecommendedSwapSize, true)
 		if err != nil {
 			return err
 		}
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Swap file resized, changing swappiness...")
 	err = ChangeSwappiness(RecommendedSwappiness)
 	if err != nil {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Swappiness changed, enabling HugePages...")
 	err = SetHugePages()
 	if err != nil {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("HugePages enabled, setting compaction proactiveness...")
 	err = SetCompactionProactiveness()
 	if err != nil {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Compaction proactiveness changed, disabling hugePage defragmentation...")
 	err = SetDefrag()
 	if err != nil {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("HugePage defragmentation disabled, setting page lock unfairness...")
 	err = SetPageLockUnfairness()
 	if err != nil {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Page lock unfairness changed, enabling Shared Memory...")
 	err = SetShMem()
 	if err != nil {
@@ -133,14 +181,19 @@ func UseRecommendedSettings() error {
 	return nil
 }
 
-func UseStockSettings() error {
+// UseStockSettings Revert every setting to stock, stopping between settings if cancelled.
+func UseStockSettings(ctx context.Context) error {
+	recordOperationDetail("preset", presetStock)
 	CryoUtils.InfoLog.Println("Resizing swap file to 1GB...")
 	// Revert swap file size
-	err := ChangeSwapSizeCLI(DefaultSwapSize, true)
+	err := ChangeSwapSizeCLI(ctx, DefaultSwapSize, true)
 	if err != nil {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Setting swappiness to 100...")
 	// Revert swappiness
 	err = ChangeSwappiness(DefaultSwappiness)
@@ -148,6 +201,9 @@ func UseStockSettings() error {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Disabling HugePages...")
 	// Enable HugePages
 	err = RevertHugePages()
@@ -155,24 +211,36 @@ func UseStockSettings() error {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Reverting compaction proactiveness...")
 	err = RevertCompactionProactiveness()
 	if err != nil {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Enabling hugePage defragmentation...")
 	err = RevertDefrag()
 	if err != nil {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Reverting page lock unfairness...")
 	err = RevertPageLockUnfairness()
 	if err != nil {
 		return err
 	}
 
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	CryoUtils.InfoLog.Println("Disabling shared memory in hugepages...")
 	err = RevertShMem()
 	if err != nil {
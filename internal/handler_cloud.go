@@ -0,0 +1,187 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/andygrunwald/vdf"
+)
+
+// Where a game's saves are, as far as CryoUtilities can tell.
+const (
+	CloudStatusSynced = "cloud-synced"
+	CloudStatusLocal  = "local saves present"
+	CloudStatusNone   = "no saves found"
+)
+
+// Parse a VDF file.
+func readVDFFile(path string) (map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := vdf.NewParser(f).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Count the files Steam Cloud keeps for an app, from a user's userdata/<id>/<appid>/remotecache.vdf.
+func readRemoteCache(path string) (int, error) {
+	m, err := readVDFFile(path)
+	if err != nil {
+		return 0, err
+	}
+	// The only section is named after the appid, each file in the cloud is a section in it
+	var files int
+	for _, section := range m {
+		entries, ok := section.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, entry := range entries {
+			if _, ok := entry.(map[string]interface{}); ok {
+				files++
+			}
+		}
+	}
+	return files, nil
+}
+
+// Get the apps a user turned Steam Cloud off for, from their userdata/<id>/7/remote/sharedconfig.vdf.
+func readCloudDisabledApps(path string) (map[int]bool, error) {
+	m, err := readVDFFile(path)
+	if err != nil {
+		return nil, err
+	}
+	section := m
+	for _, key := range []string{"UserRoamingConfigStore", "Software", "Valve", "Steam", "apps"} {
+		section, _ = getVDFValue(section, key).(map[string]interface{})
+	}
+	disabled := make(map[int]bool)
+	for key, values := range section {
+		appID, err := strconv.Atoi(key)
+		settings, ok := values.(map[string]interface{})
+		if err != nil || !ok {
+			continue
+		}
+		if enabled, _ := getVDFValue(settings, "cloudenabled").(string); enabled == "0" {
+			disabled[appID] = true
+		}
+	}
+	return disabled, nil
+}
+
+// Get the apps any Steam user on this device keeps files in Steam Cloud for, leaving out those they turned it off
+// for.
+func getCloudSyncedApps() map[int]bool {
+	synced := make(map[int]bool)
+	users, _ := filepath.Glob(filepath.Join(UserDataDirectory, "*"))
+	for _, user := range users {
+		disabled, err := readCloudDisabledApps(filepath.Join(user, "7", "remote", "sharedconfig.vdf"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			CryoUtils.ErrorLog.Println(err)
+		}
+		caches, _ := filepath.Glob(filepath.Join(user, "*", "remotecache.vdf"))
+		for _, cache := range caches {
+			appID, err := strconv.Atoi(filepath.Base(filepath.Dir(cache)))
+			if err != nil || disabled[appID] {
+				continue
+			}
+			files, err := readRemoteCache(cache)
+			if err != nil {
+				CryoUtils.ErrorLog.Println(err)
+				continue
+			}
+			if files > 0 {
+				synced[appID] = true
+			}
+		}
+	}
+	return synced
+}
+
+// Check whether a prefix holds any files where games keep their saves, ignoring what every new prefix comes with.
+func hasLocalSaves(prefix string) bool {
+	found := errors.New("found a save")
+	for _, location := range SaveLocations {
+		root := filepath.Join(prefix, location)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// Unreadable folders are skipped, a missing location just has no saves
+				return nil
+			}
+			if d.IsDir() && filepath.Dir(path) == root && Contains(SaveLocationExcludes, d.Name()) {
+				return filepath.SkipDir
+			}
+			if d.Type().IsRegular() {
+				return found
+			}
+			return nil
+		})
+		if err == found {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCloudStatus Get whether each game's saves are in Steam Cloud, only in its prefix, or not found at all, keyed
+// by appid. A game whose prefix can't be looked up is assumed to have local saves.
+func GetCloudStatus(appIDs []string) map[string]string {
+	synced := getCloudSyncedApps()
+	statuses := make(map[string]string)
+	for _, appID := range appIDs {
+		id, _ := strconv.Atoi(appID)
+		if synced[id] {
+			statuses[appID] = CloudStatusSynced
+			continue
+		}
+		prefix, err := findPrefix(appID)
+		if err != nil {
+			CryoUtils.ErrorLog.Println("Unable to find the prefix of", appID, err)
+			statuses[appID] = CloudStatusLocal
+			continue
+		}
+		if prefix != "" && hasLocalSaves(prefix) {
+			statuses[appID] = CloudStatusLocal
+		} else {
+			statuses[appID] = CloudStatusNone
+		}
+	}
+	return statuses
+}
+
+// Get the games whose only saves are in their prefix, in the order given.
+func getLocalSaveGames(appIDs []string) []string {
+	statuses := GetCloudStatus(appIDs)
+	var local []string
+	for _, appID := range appIDs {
+		if statuses[appID] == CloudStatusLocal {
+			local = append(local, appID)
+		}
+	}
+	return local
+}
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadRemoteCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     int
+		wantErr  bool
+	}{
+		{name: "Files in the cloud", contents: `"620"
+{
+	"ChangeNumber"		"42"
+	"ostype"		"-184"
+	"save/slot1.sav"
+	{
+		"root"		"0"
+		"size"		"2048"
+		"syncstate"		"1"
+	}
+	"save/slot2.sav"
+	{
+		"root"		"0"
+		"size"		"1024"
+		"syncstate"		"1"
+	}
+}`, want: 2},
+		{name: "Nothing synced yet", contents: `"400"
+{
+	"ChangeNumber"		"0"
+}`, want: 0},
+		{name: "Missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "remotecache.vdf")
+			if tt.contents != "" {
+				if err := os.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := readRemoteCache(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readRemoteCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readRemoteCache() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCloudDisabledApps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sharedconfig.vdf")
+	contents := `"UserRoamingConfigStore"
+{
+	"Software"
+	{
+		"Valve"
+		{
+			"Steam"
+			{
+				"apps"
+				{
+					"620"
+					{
+						"cloudenabled"		"0"
+					}
+					"400"
+					{
+						"CloudEnabled"		"1"
+					}
+					"1091500"
+					{
+						"LastPlayed"		"1686830400"
+					}
+				}
+			}
+		}
+	}
+}`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readCloudDisabledApps(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]bool{620: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("readCloudDisabledApps() = %v, want %v", got, want)
+	}
+}
+
+func TestHasLocalSaves(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  bool
+	}{
+		{name: "Empty prefix", want: false},
+		{name: "Only what a new prefix has", files: []string{
+			"pfx/drive_c/users/steamuser/AppData/Roaming/Microsoft/Windows/Themes/theme.ini",
+			"pfx/drive_c/users/steamuser/AppData/Local/Temp/install.log",
+		}, want: false},
+		{name: "Only shader and crash caches", files: []string{
+			"pfx/drive_c/users/steamuser/AppData/Local/D3DSCache/0123/shader.idx",
+			"pfx/drive_c/users/steamuser/AppData/Local/CrashDumps/game.exe.dmp",
+			"pfx/drive_c/users/steamuser/AppData/Local/UnrealEngine/Common/DerivedDataCache/data.ddp",
+		}, want: false},
+		{name: "Save in AppData Local", files: []string{
+			"pfx/drive_c/users/steamuser/AppData/Local/Game/Saved/SaveGames/slot1.sav",
+		}, want: true},
+		{name: "Save in Saved Games", files: []string{
+			"pfx/drive_c/users/steamuser/Saved Games/Game/slot1.sav",
+		}, want: true},
+		{name: "Save in Public Documents", files: []string{
+			"pfx/drive_c/users/Public/Documents/Game/profile.dat",
+		}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := t.TempDir()
+			for _, file := range tt.files {
+				path := filepath.Join(prefix, file)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := hasLocalSaves(prefix); got != tt.want {
+				t.Errorf("hasLocalSaves() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,469 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// CopyProgress A snapshot of how far a copy has come
+type CopyProgress struct {
+	TotalBytes  int64
+	CopiedBytes int64
+	TotalFiles  int64
+	CopiedFiles int64
+	Elapsed     time.Duration
+}
+
+// Fraction Get how much of the copy is done, from 0 to 1.
+func (p CopyProgress) Fraction() float64 {
+	if p.TotalBytes == 0 {
+		return 1
+	}
+	return float64(p.CopiedBytes) / float64(p.TotalBytes)
+}
+
+// Throughput Get the average speed of the copy so far, in bytes per second.
+func (p CopyProgress) Throughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.CopiedBytes) / p.Elapsed.Seconds()
+}
+
+// ETA Get the estimated time left, zero until there's enough to go on.
+func (p CopyProgress) ETA() time.Duration {
+	throughput := p.Throughput()
+	if throughput == 0 {
+		return 0
+	}
+	return time.Duration(float64(p.TotalBytes-p.CopiedBytes) / throughput * float64(time.Second))
+}
+
+func (p CopyProgress) String() string {
+	text := fmt.Sprintf("%s of %s, %d of %d files", GetHumanByteSize(p.CopiedBytes), GetHumanByteSize(p.TotalBytes),
+		p.CopiedFiles, p.TotalFiles)
+	if p.Throughput() > 0 {
+		text += fmt.Sprintf(", %s/s, %s left", GetHumanByteSize(int64(p.Throughput())),
+			p.ETA().Round(time.Second))
+	}
+	return text
+}
+
+// Tracks progress across every tree copied during one operation.
+// A nil tracker is valid and tracks nothing.
+type copyTracker struct {
+	totalBytes  atomic.Int64
+	copiedBytes atomic.Int64
+	totalFiles  atomic.Int64
+	copiedFiles atomic.Int64
+	lastReport  atomic.Int64
+	started     time.Time
+	onProgress  func(CopyProgress)
+}
+
+func newCopyTracker(onProgress func(CopyProgress)) *copyTracker {
+	return &copyTracker{started: time.Now(), onProgress: onProgress}
+}
+
+// Add the size of a tree to the totals, call for every tree before copying starts.
+func (t *copyTracker) addTree(ctx context.Context, path string) error {
+	if t == nil {
+		return nil
+	}
+	return filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			t.totalBytes.Add(info.Size())
+			t.totalFiles.Add(1)
+		}
+		return nil
+	})
+}
+
+func (t *copyTracker) addBytes(n int64) {
+	if t == nil {
+		return
+	}
+	t.copiedBytes.Add(n)
+	t.report(false)
+}
+
+func (t *copyTracker) fileDone() {
+	if t == nil {
+		return
+	}
+	t.copiedFiles.Add(1)
+	t.report(false)
+}
+
+func (t *copyTracker) snapshot() CopyProgress {
+	return CopyProgress{
+		TotalBytes:  t.totalBytes.Load(),
+		CopiedBytes: t.copiedBytes.Load(),
+		TotalFiles:  t.totalFiles.Load(),
+		CopiedFiles: t.copiedFiles.Load(),
+		Elapsed:     time.Since(t.started),
+	}
+}
+
+// Pass the progress on, at most once per CopyProgressInterval unless forced.
+func (t *copyTracker) report(force bool) {
+	if t == nil || t.onProgress == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := t.lastReport.Load()
+	if !force && now-last < int64(CopyProgressInterval) {
+		return
+	}
+	if !t.lastReport.CompareAndSwap(last, now) && !force {
+		return
+	}
+	t.onProgress(t.snapshot())
+}
+
+// A regular file waiting for a copy worker.
+type copyJob struct {
+	src  string
+	dest string
+	info os.FileInfo
+}
+
+// Copy a directory tree with a pool of workers, keeping symlinks, permissions, timestamps and sparse files.
+// Existing files in dest are overwritten, anything else in dest is left alone.
+func copyTree(ctx context.Context, src string, dest string, tracker *copyTracker) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan copyJob)
+	var firstErr error
+	var errOnce sync.Once
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var workers sync.WaitGroup
+	for i := 0; i < CopyWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer RecoverAndReport()
+			defer workers.Done()
+			for job := range jobs {
+				err := copyRegularFile(ctx, job.src, job.dest, job.info, tracker)
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	// Directories are walked in order, so parents always exist before their contents are queued.
+	var dirs []copyJob
+	walkErr := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		relative, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, relative)
+
+		switch {
+		case info.IsDir():
+			err = os.MkdirAll(target, 0700)
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, copyJob{src: path, dest: target, info: info})
+		case info.Mode()&os.ModeSymlink != 0:
+			return copySymlink(path, target, info)
+		case info.Mode().IsRegular():
+			select {
+			case jobs <- copyJob{src: path, dest: target, info: info}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		default:
+			CryoUtils.InfoLog.Println("Skipping special file", path)
+		}
+		return nil
+	})
+	close(jobs)
+	workers.Wait()
+	if walkErr != nil {
+		fail(walkErr)
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// Writing files changes their directory's timestamps, so directories are finished last, deepest first.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err := copyMetadata(dirs[i].dest, dirs[i].info)
+		if err != nil {
+			return err
+		}
+	}
+	tracker.report(true)
+	return nil
+}
+
+// Recreate a symlink, replacing whatever was at its location.
+func copySymlink(src string, dest string, info os.FileInfo) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(dest)
+	if err != nil {
+		return err
+	}
+	err = os.Symlink(target, dest)
+	if err != nil {
+		return err
+	}
+	return setTimes(dest, info, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// Copy a single regular file, then its permissions and timestamps.
+func copyRegularFile(ctx context.Context, src string, dest string, info os.FileInfo, tracker *copyTracker) error {
+	// Replace a symlink or directory standing where the file goes, rather than writing through it.
+	existing, err := os.Lstat(dest)
+	if err == nil && !existing.Mode().IsRegular() {
+		err = os.RemoveAll(dest)
+		if err != nil {
+			return err
+		}
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	// A reflink shares the data blocks instead of copying them, it's instant on btrfs and xfs
+	cloneErr := unix.IoctlFileClone(int(out.Fd()), int(in.Fd()))
+	if cloneErr == nil {
+		tracker.addBytes(info.Size())
+	} else {
+		err = copyFileContents(ctx, in, out, info.Size(), tracker)
+	}
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error copying %s: %w", src, err)
+	}
+	err = copyMetadata(dest, info)
+	if err != nil {
+		return err
+	}
+	tracker.fileDone()
+	return nil
+}
+
+// Copy the data in a file, skipping holes so sparse files stay sparse.
+func copyFileContents(ctx context.Context, in *os.File, out *os.File, size int64, tracker *copyTracker) error {
+	fd := int(in.Fd())
+	var offset int64
+	for offset < size {
+		dataStart, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		if errors.Is(err, syscall.ENXIO) {
+			// Only a hole is left
+			tracker.addBytes(size - offset)
+			break
+		}
+		dataEnd := size
+		if err != nil {
+			// The filesystem can't report holes, copy everything.
+			dataStart = offset
+		} else {
+			dataEnd, err = unix.Seek(fd, dataStart, unix.SEEK_HOLE)
+			if err != nil {
+				dataEnd = size
+			}
+		}
+		tracker.addBytes(dataStart - offset)
+
+		err = copyRange(ctx, in, out, dataStart, dataEnd, tracker)
+		if err != nil {
+			return err
+		}
+		offset = dataEnd
+	}
+	// Extends the file over a trailing hole
+	return out.Truncate(size)
+}
+
+// Copy bytes start to end from one file to the same place in another.
+// The kernel does the copy with copy_file_range where it can, otherwise the data passes through a buffer.
+func copyRange(ctx context.Context, in *os.File, out *os.File, start int64, end int64, tracker *copyTracker) error {
+	offset := start
+	for offset < end {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		chunk := int64(CopyBufferSize)
+		if remaining := end - offset; remaining < chunk {
+			chunk = remaining
+		}
+		inOffset, outOffset := offset, offset
+		n, err := unix.CopyFileRange(int(in.Fd()), &inOffset, int(out.Fd()), &outOffset, int(chunk), 0)
+		if err != nil {
+			if isCopyUnsupported(err) {
+				break
+			}
+			return err
+		}
+		if n == 0 {
+			// The file shrank while copying, copy what's there.
+			return nil
+		}
+		offset += int64(n)
+		tracker.addBytes(int64(n))
+	}
+
+	buffer := make([]byte, CopyBufferSize)
+	for offset < end {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		chunk := buffer
+		if remaining := end - offset; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		n, err := in.ReadAt(chunk, offset)
+		if n > 0 {
+			_, writeErr := out.WriteAt(chunk[:n], offset)
+			if writeErr != nil {
+				return writeErr
+			}
+			offset += int64(n)
+			tracker.addBytes(int64(n))
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check whether copy_file_range failed because the kernel or filesystems can't do it, rather than a real error.
+func isCopyUnsupported(err error) bool {
+	return errors.Is(err, unix.EXDEV) || errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EINVAL) ||
+		errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EBADF)
+}
+
+// Check whether src can be renamed to dest, which needs dest to be missing and on the same filesystem.
+func canRenameTree(src string, dest string) bool {
+	if doesFileExist(dest) {
+		return false
+	}
+	var srcStat, destStat unix.Stat_t
+	err := unix.Lstat(src, &srcStat)
+	if err != nil {
+		return false
+	}
+	err = unix.Stat(filepath.Dir(dest), &destStat)
+	if err != nil {
+		return false
+	}
+	return srcStat.Dev == destStat.Dev
+}
+
+// Count a tree that was moved without copying as fully copied.
+func (t *copyTracker) skipTree(ctx context.Context, path string) error {
+	if t == nil {
+		return nil
+	}
+	return filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			t.copiedBytes.Add(info.Size())
+			t.copiedFiles.Add(1)
+		}
+		return nil
+	})
+}
+
+// Copy permissions and timestamps from the source's info.
+func copyMetadata(dest string, info os.FileInfo) error {
+	err := os.Chmod(dest, info.Mode().Perm()|info.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+	if err != nil {
+		return err
+	}
+	return setTimes(dest, info, 0)
+}
+
+// Set the access and modification times of a path to those in the source's info.
+func setTimes(path string, info os.FileInfo, flags int) error {
+	mtime := unix.NsecToTimespec(info.ModTime().UnixNano())
+	atime := mtime
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		atime = unix.Timespec{Sec: stat.Atim.Sec, Nsec: stat.Atim.Nsec}
+	}
+	return unix.UtimesNanoAt(unix.AT_FDCWD, path, []unix.Timespec{atime, mtime}, flags)
+}
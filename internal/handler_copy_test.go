@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCopyTree(t *testing.T) {
+	src, dest := t.TempDir(), filepath.Join(t.TempDir(), "copy")
+	modTime := time.Date(2023, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	_ = os.MkdirAll(filepath.Join(src, "pfx", "drive_c"), 0755)
+	_ = os.WriteFile(filepath.Join(src, "pfx", "user.reg"), []byte("WINE123"), 0640)
+	_ = os.Symlink("drive_c", filepath.Join(src, "pfx", "dosdevices"))
+	// A 64MB file with a single byte of data at the end
+	sparse, _ := os.Create(filepath.Join(src, "sparse"))
+	_, _ = sparse.WriteAt([]byte{1}, 64*1024*1024-1)
+	_ = sparse.Close()
+	_ = os.Chtimes(filepath.Join(src, "pfx", "user.reg"), modTime, modTime)
+	_ = os.Chtimes(filepath.Join(src, "pfx"), modTime, modTime)
+
+	var last CopyProgress
+	tracker := newCopyTracker(func(p CopyProgress) { last = p })
+	err := tracker.addTree(context.Background(), src)
+	if err != nil {
+		t.Fatalf("addTree() error = %v", err)
+	}
+	err = copyTree(context.Background(), src, dest, tracker)
+	if err != nil {
+		t.Fatalf("copyTree() error = %v", err)
+	}
+
+	mismatches, err := compareTrees(context.Background(), src, dest, VerifyFull)
+	if err != nil || len(mismatches) != 0 {
+		t.Errorf("compareTrees() = %v, %v, want no differences", mismatches, err)
+	}
+	for _, path := range []string{filepath.Join("pfx", "user.reg"), "pfx"} {
+		info, err := os.Stat(filepath.Join(dest, path))
+		if err != nil || !info.ModTime().Equal(modTime) {
+			t.Errorf("modification time of %s = %v, want %v", path, info.ModTime(), modTime)
+		}
+	}
+	info, _ := os.Stat(filepath.Join(dest, "sparse"))
+	if blocks := info.Sys().(*syscall.Stat_t).Blocks * 512; blocks >= info.Size() {
+		t.Errorf("sparse file uses %d bytes on disk, want less than %d", blocks, info.Size())
+	}
+	if last.CopiedBytes != last.TotalBytes || last.CopiedFiles != 2 || last.TotalFiles != 2 {
+		t.Errorf("final progress = %+v, want all bytes and 2 files copied", last)
+	}
+}
+
+func TestCanRenameTree(t *testing.T) {
+	root := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(root, "src"), 0755)
+	_ = os.MkdirAll(filepath.Join(root, "existing"), 0755)
+
+	tests := []struct {
+		name string
+		dest string
+		want bool
+	}{
+		{name: "Missing destination", dest: filepath.Join(root, "new"), want: true},
+		{name: "Existing destination", dest: filepath.Join(root, "existing"), want: false},
+		{name: "Missing parent", dest: filepath.Join(root, "missing", "new"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canRenameTree(filepath.Join(root, "src"), tt.dest); got != tt.want {
+				t.Errorf("canRenameTree() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const crashReportPrefix = "crash-"
+const crashReportSuffix = ".txt"
+
+// Keeps the last few log lines in memory, so a crash report can include them.
+type recentLogBuffer struct {
+	mutex   sync.Mutex
+	lines   []string
+	partial string
+}
+
+var recentLogs = &recentLogBuffer{}
+
+func (b *recentLogBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	parts := strings.Split(b.partial+string(p), "\n")
+	b.partial = parts[len(parts)-1]
+	b.lines = append(b.lines, parts[:len(parts)-1]...)
+	if len(b.lines) > RecentLogLines {
+		b.lines = append(b.lines[:0], b.lines[len(b.lines)-RecentLogLines:]...)
+	}
+	return len(p), nil
+}
+
+func (b *recentLogBuffer) recentLines() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]string(nil), b.lines...)
+}
+
+// LogWriter Write log output to the given writer, keeping the most recent lines for crash reports.
+func LogWriter(w io.Writer) io.Writer {
+	return io.MultiWriter(w, recentLogs)
+}
+
+// RecoverAndReport Write a crash report for a panic, then exit.
+// Defer it directly at the top of main and of every goroutine, recover only works there.
+func RecoverAndReport() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	path, err := writeCrashReport(fmt.Sprint(r), stack, getCurrentOperation(), recentLogs.recentLines())
+	if CryoUtils.ErrorLog != nil {
+		CryoUtils.ErrorLog.Printf("Panic: %v\n%s", r, stack)
+	}
+	fmt.Fprintln(os.Stderr, "CryoUtilities crashed:", r)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to write crash report:", err)
+	} else {
+		fmt.Fprintln(os.Stderr, "A crash report was written to", path)
+	}
+	// The operation lock is deliberately left in place, it marks the operation as interrupted.
+	os.Exit(ExitFailure)
+}
+
+// CapturePanicOutput Send stderr to a file, so panics in goroutines we don't own (ex: Fyne callbacks) leave a trace.
+func CapturePanicOutput() error {
+	file, err := os.OpenFile(PanicOutputPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return unix.Dup3(int(file.Fd()), int(os.Stderr.Fd()), 0)
+}
+
+// CollectUnhandledCrash Turn a panic from the last GUI session that bypassed RecoverAndReport into a crash report.
+// Call it before the old log file is removed, its last lines go into the report.
+func CollectUnhandledCrash() {
+	output, err := os.ReadFile(PanicOutputPath)
+	if err != nil {
+		return
+	}
+	text := string(output)
+	start := strings.Index(text, "panic: ")
+	if start < 0 {
+		start = strings.Index(text, "fatal error: ")
+	}
+	if start < 0 {
+		return
+	}
+	_ = os.Remove(PanicOutputPath)
+
+	reason, _, _ := strings.Cut(text[start:], "\n")
+	var operation string
+	holder, _ := getInterruptedOperation()
+	if holder != nil {
+		operation = describeOperation(holder)
+	}
+	_, _ = writeCrashReport(reason, []byte(text[start:]), operation, readLastLines(LogFilePath, RecentLogLines))
+}
+
+// Read up to the last n lines of a file, nil if it can't be read.
+func readLastLines(path string, n int) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines
+}
+
+// Write a crash report to the crash report directory and return its path.
+func writeCrashReport(reason string, stack []byte, operation string, logLines []string) (string, error) {
+	err := os.MkdirAll(CrashReportDirectory, 0777)
+	if err != nil {
+		return "", err
+	}
+	if operation == "" {
+		operation = "none"
+	}
+
+	var report strings.Builder
+	fmt.Fprintln(&report, "CryoUtilities crash report")
+	fmt.Fprintln(&report, "Version:", CurrentVersionNumber)
+	fmt.Fprintln(&report, "Time:", time.Now().Format(time.RFC3339))
+	fmt.Fprintln(&report, "PID:", strconv.Itoa(os.Getpid()))
+	fmt.Fprintln(&report, "Operation:", operation)
+	fmt.Fprintln(&report, "Panic:", reason)
+	fmt.Fprintf(&report, "\nStack:\n%s\n", stack)
+	fmt.Fprintf(&report, "\nRecent log:\n%s\n", strings.Join(logLines, "\n"))
+
+	path := filepath.Join(CrashReportDirectory,
+		crashReportPrefix+time.Now().Format("20060102-150405")+crashReportSuffix)
+	err = os.WriteFile(path, []byte(report.String()), 0666)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Get the path of the newest crash report, empty if there are none.
+func getLatestCrashReport() (string, error) {
+	entries, err := os.ReadDir(CrashReportDirectory)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	// Names sort by time, and ReadDir returns them sorted
+	var latest string
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, crashReportPrefix) && strings.HasSuffix(name, crashReportSuffix) {
+			latest = name
+		}
+	}
+	if latest == "" {
+		return "", nil
+	}
+	return filepath.Join(CrashReportDirectory, latest), nil
+}
+
+// Whether an interrupted operation recorded enough to be run again.
+func canResumeOperation(holder *LockHolder) bool {
+	switch holder.Operation {
+	case OperationSwap:
+		return holder.Details["preset"] != "" || holder.Details["size"] != ""
+	case OperationGameData:
+		return holder.Details["left"] != "" && holder.Details["right"] != ""
+	}
+	return false
+}
+
+// Whether an interrupted operation can be undone, only swap resizes know what they changed.
+func canRollBackOperation(holder *LockHolder) bool {
+	return holder.Operation == OperationSwap && holder.Details["preset"] == "" && holder.Details["size"] != ""
+}
+
+// Run an interrupted swap resize or preset again.
+func resumeSwapOperation(ctx context.Context, holder *LockHolder) error {
+	switch holder.Details["preset"] {
+	case presetRecommended:
+		return UseRecommendedSettings(ctx)
+	case presetStock:
+		return UseStockSettings(ctx)
+	}
+	size, err := strconv.Atoi(holder.Details["size"])
+	if err != nil {
+		return fmt.Errorf("%w: unknown swap size %q", ErrInvalidArgument, holder.Details["size"])
+	}
+	return ChangeSwapSizeCLI(ctx, size, true)
+}
+
+// Put the swap file back to the size it had before an interrupted resize, the stock size if unknown.
+func rollBackSwapOperation(ctx context.Context, holder *LockHolder) error {
+	size, err := strconv.Atoi(holder.Details["previous_size"])
+	if err != nil || size < DefaultSwapSize {
+		size = DefaultSwapSize
+	}
+	return ChangeSwapSizeCLI(ctx, size, true)
+}
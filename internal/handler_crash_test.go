@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecentLogBuffer(t *testing.T) {
+	type args struct {
+		writes []string
+		keep   int
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "Whole lines",
+			args: args{
+				writes: []string{"one\n", "two\n"},
+				keep:   5,
+			},
+			want: []string{"one", "two"},
+		},
+		{
+			name: "Split line",
+			args: args{
+				writes: []string{"on", "e\ntw", "o\nthree"},
+				keep:   5,
+			},
+			want: []string{"one", "two"},
+		},
+		{
+			name: "Trimmed",
+			args: args{
+				writes: []string{"one\ntwo\nthree\nfour\n"},
+				keep:   2,
+			},
+			want: []string{"three", "four"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldKeep := RecentLogLines
+			RecentLogLines = tt.args.keep
+			defer func() { RecentLogLines = oldKeep }()
+
+			buffer := &recentLogBuffer{}
+			for _, write := range tt.args.writes {
+				_, _ = buffer.Write([]byte(write))
+			}
+			if got := buffer.recentLines(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recentLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/sys/mountinfo"
+)
+
+// Drive An external drive, identified by its filesystem rather than where it happens to be mounted
+type Drive struct {
+	UUID       string    `json:"uuid"`
+	Label      string    `json:"label,omitempty"`
+	Mountpoint string    `json:"mountpoint"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// Get every mounted external drive, along with its filesystem UUID and label where udev knows them.
+func getAttachedExternalDrives() ([]Drive, error) {
+	mounts, err := mountinfo.GetMounts(mountinfo.PrefixFilter(MountDirectory))
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		return nil, err
+	}
+	uuids := readDiskLinks(DiskByUUIDDirectory)
+	labels := readDiskLinks(DiskByLabelDirectory)
+
+	var drives []Drive
+	for _, mount := range mounts {
+		device, err := filepath.EvalSymlinks(mount.Source)
+		if err != nil {
+			device = mount.Source
+		}
+		drives = append(drives, Drive{
+			UUID:       uuids[device],
+			Label:      unescapeUdevName(labels[device]),
+			Mountpoint: mount.Mountpoint,
+		})
+	}
+	return drives, nil
+}
+
+// Map each device to the name of its link in one of udev's /dev/disk directories.
+func readDiskLinks(dir string) map[string]string {
+	names := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return names
+	}
+	for _, entry := range entries {
+		device, err := filepath.EvalSymlinks(filepath.Join(dir, entry.Name()))
+		if err == nil {
+			names[device] = entry.Name()
+		}
+	}
+	return names
+}
+
+// Undo udev's \xNN escaping of characters like spaces in labels.
+func unescapeUdevName(name string) string {
+	var unescaped strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+3 < len(name) && name[i+1] == 'x' {
+			value, err := strconv.ParseUint(name[i+2:i+4], 16, 8)
+			if err == nil {
+				unescaped.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		unescaped.WriteByte(name[i])
+	}
+	return unescaped.String()
+}
+
+// Read the registry of known drives, keyed by UUID.
+func readDriveRegistry() (map[string]Drive, error) {
+	registry := make(map[string]Drive)
+	contents, err := os.ReadFile(DriveRegistryPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(contents, &registry)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", DriveRegistryPath, err)
+	}
+	return registry, nil
+}
+
+func writeDriveRegistry(registry map[string]Drive) error {
+	contents, err := json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return err
+	}
+	_ = os.MkdirAll(InstallDirectory, 0777)
+	return os.WriteFile(DriveRegistryPath, contents, 0666)
+}
+
+// Get a key that changes whenever an external drive is mounted, unmounted or moved.
+func getMountKey() (string, error) {
+	mounts, err := mountinfo.GetMounts(mountinfo.PrefixFilter(MountDirectory))
+	if err != nil {
+		return "", err
+	}
+	var keys []string
+	for _, mount := range mounts {
+		keys = append(keys, mount.Source+"="+mount.Mountpoint)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\n"), nil
+}
+
+// UpdateDriveRegistry Record the attached drives, and fix the links of any known drive mounted somewhere new.
+func UpdateDriveRegistry() error {
+	drives, err := getAttachedExternalDrives()
+	if err != nil {
+		return err
+	}
+	registry, err := readDriveRegistry()
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		registry = make(map[string]Drive)
+	}
+
+	var errs []error
+	for _, drive := range drives {
+		// Without a UUID there's no telling this drive apart from the next one mounted here
+		if drive.UUID == "" {
+			continue
+		}
+		known, ok := registry[drive.UUID]
+		if ok && known.Mountpoint != drive.Mountpoint {
+			CryoUtils.InfoLog.Println("Drive", drive.UUID, "("+drive.Label+") moved from", known.Mountpoint,
+				"to", drive.Mountpoint)
+			err = WithOperationLock(OperationGameData, func() error {
+				return relinkDrive(known.Mountpoint, drive.Mountpoint)
+			})
+			if err != nil {
+				// Keep the old mountpoint, so the links are fixed next time.
+				errs = append(errs, err)
+				continue
+			}
+		}
+		drive.LastSeen = time.Now()
+		registry[drive.UUID] = drive
+	}
+
+	err = writeDriveRegistry(registry)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Point every broken link into a drive's old mountpoint at the same data under its new one.
+func relinkDrive(oldMountpoint string, newMountpoint string) error {
+	var errs []error
+	fixed := 0
+	for _, root := range []string{SteamCompatRoot, SteamShaderRoot} {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			path := filepath.Join(root, entry.Name())
+			if !isSymbolicLink(path) {
+				continue
+			}
+			target, err := os.Readlink(path)
+			if err != nil || !strings.HasPrefix(target, oldMountpoint+string(filepath.Separator)) {
+				continue
+			}
+			// Leave links that still work, another drive may have taken the old mountpoint
+			newTarget := newMountpoint + strings.TrimPrefix(target, oldMountpoint)
+			if doesFileExist(target) || !doesFileExist(newTarget) {
+				continue
+			}
+			err = replaceWithSymlink(newTarget, path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			fixed++
+		}
+	}
+	CryoUtils.InfoLog.Println("Fixed", fixed, "links for drive now at", newMountpoint)
+	return errors.Join(errs...)
+}
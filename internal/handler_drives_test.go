@@ -0,0 +1,24 @@
+package internal
+
+import "testing"
+
+func TestUnescapeUdevName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "SteamDeck", want: "SteamDeck"},
+		{name: `Steam\x20Deck\x20SD`, want: "Steam Deck SD"},
+		{name: `back\x2fslash`, want: "back/slash"},
+		{name: `trailing\x2`, want: `trailing\x2`},
+		{name: `not\xzzhex`, want: `not\xzzhex`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unescapeUdevName(tt.name); got != tt.want {
+				t.Errorf("unescapeUdevName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,12 +17,14 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
-
-	cp "github.com/otiai10/copy"
+	"strings"
+	"time"
 )
 
 type StorageStatus struct {
@@ -32,13 +34,79 @@ type StorageStatus struct {
 	RightShaderDirectories []string
 }
 
+// DataToMove The data queued to leave each side of a sync, right is moved left and left is moved right.
 type DataToMove struct {
-	right     []string
-	left      []string
+	right     dataQueue
+	left      dataQueue
 	rightSize int64
 	leftSize  int64
 }
 
+// The games whose compatdata and shadercache are queued to leave one side, each kind is moved on its own.
+type dataQueue struct {
+	compat []string
+	shader []string
+	// The size of each game's queued data, once getSize has run
+	sizes map[string]int64
+}
+
+// Get every game with anything queued, in the order they were queued.
+func (q dataQueue) games() []string {
+	games := append([]string{}, q.compat...)
+	for _, game := range q.shader {
+		if !Contains(games, game) {
+			games = append(games, game)
+		}
+	}
+	return games
+}
+
+func (q dataQueue) isEmpty() bool {
+	return len(q.compat) == 0 && len(q.shader) == 0
+}
+
+// Take a game out of the queue entirely.
+func (q *dataQueue) remove(game string) {
+	q.compat = removeElementFromStringSlice(game, q.compat)
+	q.shader = removeElementFromStringSlice(game, q.shader)
+	delete(q.sizes, game)
+}
+
+// Get the size of each queued game's data, as found by getSize.
+func (q dataQueue) gameSizes() []gameSize {
+	var sizes []gameSize
+	for _, game := range q.games() {
+		sizes = append(sizes, gameSize{appID: game, size: q.sizes[game]})
+	}
+	return sizes
+}
+
+// Describe which kinds of a game's data are queued, for listing to the user.
+func (q dataQueue) describe(game string) string {
+	hasCompat, hasShader := Contains(q.compat, game), Contains(q.shader, game)
+	switch {
+	case hasCompat && hasShader:
+		return "compatdata and shadercache"
+	case hasCompat:
+		return "compatdata only"
+	case hasShader:
+		return "shadercache only"
+	}
+	return ""
+}
+
+// Get the moves for each kind of a queued game's data, nil for a kind that isn't queued.
+func (q dataQueue) moves(game string, compat dataMove, shader dataMove) (*dataMove, *dataMove) {
+	var compatMove, shaderMove *dataMove
+	if Contains(q.compat, game) {
+		compatMove = &compat
+	}
+	if Contains(q.shader, game) {
+		shaderMove = &shader
+	}
+	return compatMove, shaderMove
+}
+
 // Get a list of the directories inside the provided directory, ignoring symbolic links
 func getDirectoryList(path string, includeSymlinks bool) ([]string, error) {
 	var folderList []string
@@ -123,33 +191,41 @@ func (s *StorageStatus) getStorageStatus(left string, right string) error {
 	return nil
 }
 
-func (d *DataToMove) getSpaceNeeded(left string, right string) {
-	var leftCompat, rightCompat, leftShader, rightShader string
-	if left == SteamDataRoot {
-		leftCompat = SteamCompatRoot
-		leftShader = SteamShaderRoot
-	} else {
-		leftCompat = filepath.Join(left, ExternalCompatRoot)
-		leftShader = filepath.Join(left, ExternalShaderRoot)
+// Total up the size of the data queued in each direction, stopping early if cancelled.
+func (d *DataToMove) getSpaceNeeded(ctx context.Context, left string, right string) error {
+	var err error
+	d.leftSize, err = d.left.getSize(ctx, left)
+	if err != nil {
+		return err
 	}
+	d.rightSize, err = d.right.getSize(ctx, right)
+	return err
+}
 
-	if right == SteamDataRoot {
-		rightCompat = SteamCompatRoot
-		rightShader = SteamShaderRoot
-	} else {
-		rightCompat = filepath.Join(right, ExternalCompatRoot)
-		rightShader = filepath.Join(right, ExternalShaderRoot)
+// Total up the size of the queued data on a drive, per game and overall, stopping early if cancelled.
+func (q *dataQueue) getSize(ctx context.Context, drive string) (int64, error) {
+	compat, shader := getDataRoots(drive)
+	var paths []string
+	for _, game := range q.compat {
+		paths = append(paths, filepath.Join(compat, game))
 	}
-
-	for x := range d.left {
-		d.leftSize += getDirectorySize(filepath.Join(leftCompat, d.left[x]))
-		d.leftSize += getDirectorySize(filepath.Join(leftShader, d.left[x]))
+	for _, game := range q.shader {
+		paths = append(paths, filepath.Join(shader, game))
+	}
+	measured, err := getDirectorySizes(ctx, paths)
+	if err != nil {
+		return 0, err
 	}
 
-	for x := range d.right {
-		d.rightSize += getDirectorySize(filepath.Join(rightCompat, d.right[x]))
-		d.rightSize += getDirectorySize(filepath.Join(rightShader, d.right[x]))
+	q.sizes = make(map[string]int64)
+	var size int64
+	for path, pathSize := range measured {
+		q.sizes[filepath.Base(path)] += pathSize
 	}
+	for _, gameSize := range q.sizes {
+		size += gameSize
+	}
+	return size, nil
 }
 
 // Populate a DataToMove object with the current queue of data needing to be moved.
@@ -171,9 +247,12 @@ func (d *DataToMove) getDataToMove(left string, right string) error {
 			for _, game := range libraries[i].InstalledGames {
 				gameString := strconv.Itoa(game)
 				CryoUtils.InfoLog.Println("Library contains:", gameString)
-				// If game has files on the right side
-				if contains(storage.RightCompatDirectories, gameString) && contains(storage.RightShaderDirectories, gameString) {
-					d.right = append(d.right, gameString)
+				// Queue each kind of data the game has on the right side
+				if Contains(storage.RightCompatDirectories, gameString) {
+					d.right.compat = append(d.right.compat, gameString)
+				}
+				if Contains(storage.RightShaderDirectories, gameString) {
+					d.right.shader = append(d.right.shader, gameString)
 				}
 			}
 		} else if isSubPath(right, libraries[i].Path) {
@@ -182,9 +261,12 @@ func (d *DataToMove) getDataToMove(left string, right string) error {
 			for _, game := range libraries[i].InstalledGames {
 				gameString := strconv.Itoa(game)
 				CryoUtils.InfoLog.Println("Library contains:", gameString)
-				// If game is installed on the right side AND has files on the left side
-				if contains(storage.LeftCompatDirectories, gameString) && contains(storage.LeftShaderDirectories, gameString) {
-					d.left = append(d.left, gameString)
+				// If game is installed on the right side, queue each kind of data it has on the left side
+				if Contains(storage.LeftCompatDirectories, gameString) {
+					d.left.compat = append(d.left.compat, gameString)
+				}
+				if Contains(storage.LeftShaderDirectories, gameString) {
+					d.left.shader = append(d.left.shader, gameString)
 				}
 			}
 		} else {
@@ -194,156 +276,163 @@ func (d *DataToMove) getDataToMove(left string, right string) error {
 	return nil
 }
 
-// Move game data between each location as necessary
-func moveGameData(data DataToMove, left string, right string) error {
-	var progressPerMove = 1.0 / float64(len(data.right)+len(data.left))
-	var leftCompatPath, leftShaderPath, rightCompatPath, rightShaderPath string
-
-	if left == SteamDataRoot {
-		leftCompatPath = SteamCompatRoot
-		leftShaderPath = SteamShaderRoot
-	} else {
-		leftCompatPath = filepath.Join(left, ExternalCompatRoot)
-		leftShaderPath = filepath.Join(left, ExternalShaderRoot)
-	}
-
-	if right == SteamDataRoot {
-		rightCompatPath = SteamCompatRoot
-		rightShaderPath = SteamShaderRoot
-	} else {
-		rightCompatPath = filepath.Join(right, ExternalCompatRoot)
-		rightShaderPath = filepath.Join(right, ExternalShaderRoot)
+// Move game data between each location as necessary, reporting copy progress to onProgress if it isn't nil.
+// Cancelling stops before the next game, or discards the copy of the game currently being moved.
+func moveGameData(ctx context.Context, data DataToMove, left string, right string,
+	onProgress func(CopyProgress)) error {
+	recordOperationDetail("left", left)
+	recordOperationDetail("right", right)
+	leftCompatPath, leftShaderPath := getDataRoots(left)
+	rightCompatPath, rightShaderPath := getDataRoots(right)
+
+	// Walk everything up front, so progress covers the whole sync rather than one game at a time
+	tracker := newCopyTracker(onProgress)
+	for _, queued := range []struct {
+		games []string
+		root  string
+	}{
+		{data.right.compat, rightCompatPath},
+		{data.right.shader, rightShaderPath},
+		{data.left.compat, leftCompatPath},
+		{data.left.shader, leftShaderPath},
+	} {
+		for _, directory := range queued.games {
+			err := tracker.addTree(ctx, filepath.Join(queued.root, directory))
+			if err != nil {
+				return err
+			}
+		}
 	}
+	CryoUtils.InfoLog.Println("Moving", GetHumanByteSize(tracker.totalBytes.Load()), "in",
+		tracker.totalFiles.Load(), "files")
 
 	// Moving to the left
-	for _, directory := range data.right {
-		leftCompatDir := filepath.Join(leftCompatPath, directory)
-		leftShaderDir := filepath.Join(leftShaderPath, directory)
-		rightCompatDir := filepath.Join(rightCompatPath, directory)
-		rightShaderDir := filepath.Join(rightShaderPath, directory)
-
-		// Remove any symlinks on the SSD in preparation for either moving to the SSD, or creating new symlinks
-		steamCompatDir := filepath.Join(SteamCompatRoot, directory)
-		if isSymbolicLink(steamCompatDir) {
-			_ = os.Remove(steamCompatDir)
-		}
-		steamShaderDir := filepath.Join(SteamShaderRoot, directory)
-		if isSymbolicLink(steamShaderDir) {
-			_ = os.Remove(steamShaderDir)
-		}
-
-		// Copy the files
-		CryoUtils.InfoLog.Println("Moving " + directory + " left...")
-		err := cp.Copy(rightCompatDir, leftCompatDir)
+	for _, directory := range data.right.games() {
+		CryoUtils.InfoLog.Println("Moving " + directory + " left (" + data.right.describe(directory) + ")...")
+		compat, shader := data.right.moves(directory, dataMove{rightCompatPath, leftCompatPath},
+			dataMove{rightShaderPath, leftShaderPath})
+		err := moveGameDirectory(ctx, directory, compat, shader, tracker)
 		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
 			return err
 		}
-		err = cp.Copy(rightShaderDir, leftShaderDir)
+	}
+
+	// Moving to the right
+	for _, directory := range data.left.games() {
+		CryoUtils.InfoLog.Println("Moving " + directory + " right (" + data.left.describe(directory) + ")...")
+		compat, shader := data.left.moves(directory, dataMove{leftCompatPath, rightCompatPath},
+			dataMove{leftShaderPath, rightShaderPath})
+		err := moveGameDirectory(ctx, directory, compat, shader, tracker)
 		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
 			return err
 		}
+	}
+	CryoUtils.InfoLog.Println("Move finished:", tracker.snapshot())
+	return nil
+}
 
-		// Remove the old files on the right
-		CryoUtils.InfoLog.Println("Removing old " + rightCompatDir)
-		err = os.RemoveAll(rightCompatDir)
-		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
-			return err
+// Check a finished copy against its source before the source is deleted, as thoroughly as the settings ask.
+func verifyMove(ctx context.Context, entry *MoveJournalEntry) error {
+	mode := GetVerifyMode()
+	CryoUtils.InfoLog.Println("Verifying copy of", entry.AppID, "("+mode+")")
+
+	var mismatches []string
+	for _, pair := range entry.pairs() {
+		if !doesFileExist(pair.to) {
+			mismatches = append(mismatches, pair.to+": missing")
+			continue
 		}
-		waitForDeletion(rightCompatPath, directory)
-		CryoUtils.InfoLog.Println("Removing old " + rightShaderDir)
-		err = os.RemoveAll(rightShaderDir)
+		// A rename moves the data itself, there's no copy to check
+		if mode == VerifyOff || *pair.renamed {
+			continue
+		}
+		found, err := compareTrees(ctx, pair.from, pair.to, mode)
 		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
-			return err
+			return fmt.Errorf("error verifying data for %s: %w", entry.AppID, err)
 		}
-		waitForDeletion(rightShaderPath, directory)
+		mismatches = append(mismatches, found...)
+	}
+
+	if len(mismatches) != 0 {
+		CryoUtils.ErrorLog.Println("Verification of", entry.AppID, "failed:\n"+strings.Join(mismatches, "\n"))
+		return &VerificationError{AppID: entry.AppID, Mismatches: mismatches}
+	}
+	return nil
+}
+
+// Where one kind of a game's data moves from and to, as the folders holding every game's data of that kind.
+type dataMove struct {
+	fromRoot string
+	toRoot   string
+}
+
+// Move the compatdata and shadercache of a single game from one location to another, a nil move skips that kind.
+// Each step is recorded in the move journal, so a move that is cut off can be resumed or rolled back later.
+func moveGameDirectory(ctx context.Context, directory string, compat *dataMove, shader *dataMove,
+	tracker *copyTracker) error {
+	// Safe-stop point, nothing for this game has been touched yet
+	if ctx.Err() != nil {
+		CryoUtils.InfoLog.Println("Move cancelled before", directory)
+		return ctx.Err()
+	}
+	if isGameRunning(directory) {
+		return fmt.Errorf("cannot move data for %s: %w", directory, ErrSteamRunning)
+	}
 
+	entry := &MoveJournalEntry{
+		AppID:   directory,
+		Started: time.Now(),
+	}
+	if compat != nil {
+		entry.FromCompatDir = filepath.Join(compat.fromRoot, directory)
+		entry.ToCompatDir = filepath.Join(compat.toRoot, directory)
 		// If the destination is NOT on the SSD, make symlinks
-		if leftCompatPath != SteamCompatRoot {
-			// Create symlinks on the SSD to the new location
-			CryoUtils.InfoLog.Println("Creating symlink to new path on SSD...")
-			err = os.Symlink(leftCompatDir, filepath.Join(SteamCompatRoot, directory))
-			if err != nil {
-				CryoUtils.ErrorLog.Println(err)
-				return err
-			}
-			err = os.Symlink(leftShaderDir, filepath.Join(SteamShaderRoot, directory))
-			if err != nil {
-				CryoUtils.ErrorLog.Println(err)
-				return err
-			}
-		}
+		entry.CompatLink = compat.toRoot != SteamCompatRoot
+		entry.CompatLinked = isSymbolicLink(entry.steamCompatDir())
+		// A symlink at the destination is about to be removed, it doesn't count as existing data
+		entry.CompatExisted = doesFileExist(entry.ToCompatDir) &&
+			!(entry.CompatLinked && entry.ToCompatDir == entry.steamCompatDir())
+	}
+	if shader != nil {
+		entry.FromShaderDir = filepath.Join(shader.fromRoot, directory)
+		entry.ToShaderDir = filepath.Join(shader.toRoot, directory)
+		entry.ShaderLink = shader.toRoot != SteamShaderRoot
+		entry.ShaderLinked = isSymbolicLink(entry.steamShaderDir())
+		entry.ShaderExisted = doesFileExist(entry.ToShaderDir) &&
+			!(entry.ShaderLinked && entry.ToShaderDir == entry.steamShaderDir())
+	}
 
-		CryoUtils.MoveDataProgressBar.SetValue(CryoUtils.MoveDataProgressBar.Value + progressPerMove)
+	err := recordMoveStep(entry, moveStepCopy)
+	if err != nil {
+		return err
 	}
 
-	// Moving to the right
-	for _, directory := range data.left {
-		leftCompatDir := filepath.Join(leftCompatPath, directory)
-		leftShaderDir := filepath.Join(leftShaderPath, directory)
-		rightCompatDir := filepath.Join(rightCompatPath, directory)
-		rightShaderDir := filepath.Join(rightShaderPath, directory)
-
-		// Remove any symlinks on the SSD in preparation for either moving to the SSD, or creating new symlinks
-		steamCompatDir := filepath.Join(SteamCompatRoot, directory)
-		if isSymbolicLink(steamCompatDir) {
-			_ = os.Remove(steamCompatDir)
-		}
-		steamShaderDir := filepath.Join(SteamShaderRoot, directory)
-		if isSymbolicLink(steamShaderDir) {
-			_ = os.Remove(steamShaderDir)
+	// Remove any symlinks on the SSD in preparation for either moving to the SSD, or creating new symlinks
+	for _, pair := range entry.pairs() {
+		if pair.linked {
+			_ = os.Remove(pair.steamDir)
 		}
+	}
 
-		// Copy the files
-		CryoUtils.InfoLog.Println("Moving " + directory + " right...")
-		err := cp.Copy(leftCompatDir, rightCompatDir)
-		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
-			return err
-		}
-		err = cp.Copy(leftShaderDir, rightShaderDir)
-		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
-			return err
-		}
+	return runMoveSteps(ctx, entry, tracker)
+}
 
-		// Remove the old files on the left
-		CryoUtils.InfoLog.Println("Removing old " + leftCompatDir)
-		err = os.RemoveAll(leftCompatDir)
+// Check whether Steam is currently running the game, by looking for the reaper process it launches games with.
+func isGameRunning(appID string) bool {
+	processes, _ := filepath.Glob("/proc/[0-9]*/cmdline")
+	needle := []byte("AppId=" + appID)
+	for _, process := range processes {
+		cmdline, err := os.ReadFile(process)
 		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
-			return err
+			continue
 		}
-		waitForDeletion(leftCompatPath, directory)
-		CryoUtils.InfoLog.Println("Removing old " + leftShaderDir)
-		err = os.RemoveAll(leftShaderDir)
-		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
-			return err
-		}
-		waitForDeletion(leftShaderPath, directory)
-
-		// If the destination is NOT on the SSD, make symlinks
-		if rightCompatPath != SteamCompatRoot {
-			// Create symlinks on the SSD to the new location
-			CryoUtils.InfoLog.Println("Creating symlink to new path on SSD...")
-			err = os.Symlink(rightCompatDir, filepath.Join(SteamCompatRoot, directory))
-			if err != nil {
-				CryoUtils.ErrorLog.Println(err)
-				return err
-			}
-			err = os.Symlink(rightShaderDir, filepath.Join(SteamShaderRoot, directory))
-			if err != nil {
-				CryoUtils.ErrorLog.Println(err)
-				return err
+		for _, arg := range bytes.Split(cmdline, []byte{0}) {
+			if bytes.Equal(arg, needle) {
+				return true
 			}
 		}
-		CryoUtils.MoveDataProgressBar.SetValue(CryoUtils.MoveDataProgressBar.Value + progressPerMove)
 	}
-	return nil
+	return false
 }
 
 // Confirm that all directories are in the proper locations post-move.
@@ -352,28 +441,20 @@ func (d *DataToMove) confirmDirectoryStatus(left string, right string) (bool, er
 	var dirs StorageStatus
 	_ = dirs.getStorageStatus(left, right)
 
-	for _, directory := range d.right {
-		for _, x := range dirs.RightCompatDirectories {
-			if x == directory {
-				unmoved = append(unmoved, directory)
-			}
-		}
-		for _, x := range dirs.RightShaderDirectories {
-			if x == directory {
-				unmoved = append(unmoved, directory)
-			}
-		}
-	}
-
-	for _, directory := range d.left {
-		for _, x := range dirs.LeftCompatDirectories {
-			if x == directory {
-				unmoved = append(unmoved, directory)
-			}
-		}
-		for _, x := range dirs.LeftShaderDirectories {
-			if x == directory {
-				unmoved = append(unmoved, directory)
+	// Each kind of data is checked on its own, so only what was queued is reported
+	for _, check := range []struct {
+		queued    []string
+		remaining []string
+		kind      string
+	}{
+		{d.right.compat, dirs.RightCompatDirectories, "compatdata"},
+		{d.right.shader, dirs.RightShaderDirectories, "shadercache"},
+		{d.left.compat, dirs.LeftCompatDirectories, "compatdata"},
+		{d.left.shader, dirs.LeftShaderDirectories, "shadercache"},
+	} {
+		for _, directory := range check.queued {
+			if Contains(check.remaining, directory) {
+				unmoved = append(unmoved, directory+" ("+check.kind+")")
 			}
 		}
 	}
@@ -382,19 +463,19 @@ func (d *DataToMove) confirmDirectoryStatus(left string, right string) (bool, er
 		return true, nil
 	} else {
 		return false, fmt.Errorf("the following directories remain in the incorrect locations:\n"+
-			"%s", unmoved)
+			"%s", strings.Join(unmoved, "\n"))
 	}
 }
 
-func getUninstalledGamesData() (uninstalled []string) {
+func getUninstalledGamesData(ctx context.Context) (uninstalled []string) {
 
-	localGames, err := getLocalGameList()
+	localGames, err := getLocalGameList(ctx)
 	if err != nil {
 		return nil
 	}
 
 	for key, game := range localGames {
-		if key != 0 && key <= SteamGameMaxInteger && !game.IsInstalled {
+		if !game.IsInstalled && isManagedApp(key) {
 			uninstalled = append(uninstalled, strconv.Itoa(key))
 		}
 	}
@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDataQueue(t *testing.T) {
+	queue := dataQueue{compat: []string{"100", "200"}, shader: []string{"200", "300"}}
+
+	if got, want := queue.games(), []string{"100", "200", "300"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("games() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		game       string
+		want       string
+		wantCompat bool
+		wantShader bool
+	}{
+		{game: "100", want: "compatdata only", wantCompat: true},
+		{game: "200", want: "compatdata and shadercache", wantCompat: true, wantShader: true},
+		{game: "300", want: "shadercache only", wantShader: true},
+		{game: "400", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.game, func(t *testing.T) {
+			if got := queue.describe(tt.game); got != tt.want {
+				t.Errorf("describe() = %q, want %q", got, tt.want)
+			}
+			compat, shader := queue.moves(tt.game, dataMove{"a", "b"}, dataMove{"c", "d"})
+			if (compat != nil) != tt.wantCompat || (shader != nil) != tt.wantShader {
+				t.Errorf("moves() = %v, %v, want compat %v, shader %v", compat, shader, tt.wantCompat,
+					tt.wantShader)
+			}
+		})
+	}
+}
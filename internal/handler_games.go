@@ -0,0 +1,239 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GameInfo What CryoUtilities knows about one game's data, as listed by the games command.
+type GameInfo struct {
+	AppID     int    `json:"appid"`
+	Name      string `json:"name"`
+	Installed bool   `json:"installed"`
+	Location  string `json:"location"`
+	// The drive each kind of data is on, empty if the game has none
+	CompatDrive string `json:"compat_drive"`
+	ShaderDrive string `json:"shader_drive"`
+	CompatPath  string `json:"compat_path"`
+	ShaderPath  string `json:"shader_path"`
+	CompatSize  int64  `json:"compat_size"`
+	ShaderSize  int64  `json:"shader_size"`
+	// What's on the SSD for each kind of data, see the LinkStatus constants, empty if there's nothing
+	CompatLink string `json:"compat_link"`
+	ShaderLink string `json:"shader_link"`
+	// From the game's manifest, if it's in a library
+	InstallDir  string    `json:"install_dir,omitempty"`
+	SizeOnDisk  int64     `json:"size_on_disk,omitempty"`
+	LastUpdated time.Time `json:"last_updated,omitempty"`
+}
+
+// TotalSize The space the game's compatdata and shadercache take together.
+func (g GameInfo) TotalSize() int64 {
+	return g.CompatSize + g.ShaderSize
+}
+
+// SyncPreview The data a sync between two drives moves, or would move on a dry run.
+type SyncPreview struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Games whose data moves to each drive, and the kinds of data that move
+	ToFrom map[string]string `json:"to_from"`
+	ToTo   map[string]string `json:"to_to"`
+	Spaces []DriveSpace      `json:"spaces"`
+}
+
+// ListGames Get every game with compatdata or shadercache on an attached drive, sorted by appid.
+func ListGames(ctx context.Context) ([]GameInfo, error) {
+	return listGames(ctx, false)
+}
+
+// List every game's data, with the sizes from the last time it was measured if estimate is set. Those can be brought
+// up to date later with refineGameSizes.
+func listGames(ctx context.Context, estimate bool) ([]GameInfo, error) {
+	localGames, err := getLocalGameList(ctx)
+	if err != nil {
+		return nil, err
+	}
+	located, err := LocateGameData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := getAppManifests()
+	if err != nil {
+		return nil, err
+	}
+	drives, err := getListOfAttachedDrives()
+	if err != nil {
+		return nil, err
+	}
+
+	var games []GameInfo
+	for _, data := range located {
+		appID, _ := strconv.Atoi(data.AppID)
+		game := GameInfo{
+			AppID:       appID,
+			Name:        localGames[appID].GameName,
+			Installed:   localGames[appID].IsInstalled,
+			Location:    data.Location(),
+			CompatDrive: data.CompatDrive,
+			ShaderDrive: data.ShaderDrive,
+			InstallDir:  manifests[appID].InstallDir,
+			SizeOnDisk:  manifests[appID].SizeOnDisk,
+			LastUpdated: manifests[appID].LastUpdated,
+		}
+		if link, ok := classifyDataLink(filepath.Join(SteamCompatRoot, data.AppID), drives); ok {
+			game.CompatLink = link.Status
+		}
+		if link, ok := classifyDataLink(filepath.Join(SteamShaderRoot, data.AppID), drives); ok {
+			game.ShaderLink = link.Status
+		}
+		for _, path := range data.paths(DataTypeCompat) {
+			game.CompatPath = path
+		}
+		for _, path := range data.paths(DataTypeShader) {
+			game.ShaderPath = path
+		}
+		games = append(games, game)
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].AppID < games[j].AppID
+	})
+
+	if estimate {
+		setGameSizes(games, estimateDirectorySizes(ctx, getGamePaths(games)))
+		return games, nil
+	}
+	err = refineGameSizes(ctx, games)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		// A listing is still useful with a size that's a little short
+		CryoUtils.ErrorLog.Println(err)
+	}
+	return games, nil
+}
+
+// Get the paths of every game's data.
+func getGamePaths(games []GameInfo) []string {
+	var paths []string
+	for _, game := range games {
+		for _, path := range []string{game.CompatPath, game.ShaderPath} {
+			if path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+func setGameSizes(games []GameInfo, sizes map[string]int64) {
+	for i := range games {
+		games[i].CompatSize = sizes[games[i].CompatPath]
+		games[i].ShaderSize = sizes[games[i].ShaderPath]
+	}
+}
+
+// Measure every game's data again, replacing any estimated sizes. What could be counted is kept if part of the data
+// can't be read.
+func refineGameSizes(ctx context.Context, games []GameInfo) error {
+	sizes, err := getDirectorySizes(ctx, getGamePaths(games))
+	setGameSizes(games, sizes)
+	return err
+}
+
+// FindGame Get what's known about a single game's data.
+func FindGame(ctx context.Context, appID int) (GameInfo, error) {
+	games, err := ListGames(ctx)
+	if err != nil {
+		return GameInfo{}, err
+	}
+	for _, game := range games {
+		if game.AppID == appID {
+			return game, nil
+		}
+	}
+	return GameInfo{}, fmt.Errorf("%w: no compatdata or shadercache found for %d", ErrInvalidArgument, appID)
+}
+
+// SyncGames Sync game data between two drives, the same way the Sync window does, reporting copy progress to
+// onProgress if it isn't nil. A dry run only works out what would move.
+func SyncGames(ctx context.Context, from string, to string, dryRun bool,
+	onProgress func(CopyProgress)) (*SyncPreview, error) {
+	var data DataToMove
+	err := data.getDataToMove(from, to)
+	if err != nil {
+		return nil, err
+	}
+	spaces, err := data.planSpace(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &SyncPreview{From: from, To: to, ToFrom: make(map[string]string), ToTo: make(map[string]string),
+		Spaces: spaces}
+	for _, game := range data.right.games() {
+		preview.ToFrom[game] = data.right.describe(game)
+	}
+	for _, game := range data.left.games() {
+		preview.ToTo[game] = data.left.describe(game)
+	}
+	if dryRun || (data.left.isEmpty() && data.right.isEmpty()) {
+		return preview, nil
+	}
+
+	err = moveGameData(ctx, data, from, to, onProgress)
+	if err != nil {
+		return preview, err
+	}
+	_, err = data.confirmDirectoryStatus(from, to)
+	return preview, err
+}
+
+// CleanGames Quarantine the compatdata and shadercache of the given games, or of every uninstalled game, on every
+// attached drive. Games whose saves aren't in Steam Cloud are refused unless localSaves is set. Returns the games that
+// were cleaned.
+func CleanGames(ctx context.Context, appIDs []string, uninstalled bool, localSaves bool) ([]string, error) {
+	if uninstalled {
+		appIDs = getUninstalledGamesData(ctx)
+	}
+	for _, appID := range appIDs {
+		// Never touch Proton or Steam runtime folders
+		if !isGameDataDirectory(appID) {
+			return nil, fmt.Errorf("%w: %q is not a game's appid", ErrInvalidArgument, appID)
+		}
+	}
+	if !localSaves {
+		local := getLocalSaveGames(appIDs)
+		if len(local) != 0 {
+			return nil, fmt.Errorf("%w: %s", ErrLocalSaves, strings.Join(local, ", "))
+		}
+	}
+	locations, err := getListOfDataAllDataLocations()
+	if err != nil {
+		return nil, err
+	}
+	recordOperationDetail("games", fmt.Sprint(appIDs))
+	return appIDs, removeGameData(ctx, appIDs, locations)
+}
@@ -0,0 +1,169 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Commands a second launch can send to the running GUI.
+const (
+	instanceCommandFocus = "focus"
+	instanceCommandTab   = "tab"
+)
+
+var instanceDialTimeout = time.Second
+
+// Get the per-user socket location, preferring the user's runtime directory.
+func getInstanceSocketPath() string {
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = InstallDirectory
+	}
+	return filepath.Join(runtimeDir, "cryoutilities.sock")
+}
+
+// Turn the arguments of the gui command into a command for the running instance.
+func buildInstanceCommand(args []string) string {
+	if len(args) > 0 {
+		return instanceCommandTab + " " + args[0]
+	}
+	return instanceCommandFocus
+}
+
+// Send a command to the running instance and return its reply.
+func sendInstanceCommand(command string) (string, error) {
+	conn, err := net.DialTimeout("unix", InstanceSocketPath, instanceDialTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(instanceDialTimeout))
+
+	_, err = fmt.Fprintln(conn, command)
+	if err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reply), nil
+}
+
+// ActivateRunningInstance Hand the gui arguments to an already running GUI.
+// Returns true if another instance is running and this one should exit.
+func ActivateRunningInstance(args []string) (bool, error) {
+	reply, err := sendInstanceCommand(buildInstanceCommand(args))
+	if err != nil {
+		// Nobody is listening, so this is the only instance.
+		return false, nil
+	}
+	if reply != "ok" {
+		return true, errors.New(reply)
+	}
+	return true, nil
+}
+
+// Start listening for other launches, cleaning up the socket of an instance that didn't exit cleanly.
+func listenForInstances() (net.Listener, error) {
+	if doesFileExist(InstanceSocketPath) {
+		_, err := sendInstanceCommand(instanceCommandFocus)
+		if err == nil {
+			return nil, fmt.Errorf("CryoUtilities is already running")
+		}
+		CryoUtils.InfoLog.Println("Removing stale instance socket", InstanceSocketPath)
+		_ = os.Remove(InstanceSocketPath)
+	}
+
+	listener, err := net.Listen("unix", InstanceSocketPath)
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Chmod(InstanceSocketPath, 0600)
+	CryoUtils.InfoLog.Println("Listening for other instances on", InstanceSocketPath)
+	return listener, nil
+}
+
+// Answer commands from other launches until the listener is closed.
+func (app *Config) serveInstanceRequests(listener net.Listener) {
+	defer RecoverAndReport()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				CryoUtils.ErrorLog.Println(err)
+			}
+			return
+		}
+		go app.handleInstanceConnection(conn)
+	}
+}
+
+func (app *Config) handleInstanceConnection(conn net.Conn) {
+	defer RecoverAndReport()
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(instanceDialTimeout))
+
+	command, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		return
+	}
+	command = strings.TrimSpace(command)
+	CryoUtils.InfoLog.Println("Received command from another instance:", command)
+
+	err = app.runInstanceCommand(command)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		_, _ = fmt.Fprintln(conn, err.Error())
+		return
+	}
+	_, _ = fmt.Fprintln(conn, "ok")
+}
+
+func (app *Config) runInstanceCommand(command string) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty command")
+	}
+
+	switch fields[0] {
+	case instanceCommandFocus:
+	case instanceCommandTab:
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: tab <name>")
+		}
+		err := app.selectTab(fields[1])
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown command %q", fields[0])
+	}
+
+	app.MainWindow.Show()
+	app.MainWindow.RequestFocus()
+	return nil
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/test"
+	"fyne.io/fyne/v2/widget"
+)
+
+func TestBuildInstanceCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "No arguments", want: "focus"},
+		{name: "Tab", args: []string{"storage"}, want: "tab storage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildInstanceCommand(tt.args); got != tt.want {
+				t.Errorf("buildInstanceCommand(%v) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstanceCommands(t *testing.T) {
+	oldSocketPath := InstanceSocketPath
+	InstanceSocketPath = filepath.Join(t.TempDir(), "cryoutilities.sock")
+	defer func() { InstanceSocketPath = oldSocketPath }()
+
+	app := &Config{
+		MainWindow: test.NewApp().NewWindow("CryoUtilities"),
+		MainTabs: container.NewAppTabs(
+			container.NewTabItem("Home", widget.NewLabel("Home")),
+			container.NewTabItem("Storage", widget.NewLabel("Storage")),
+		),
+	}
+	listener, err := listenForInstances()
+	if err != nil {
+		t.Fatalf("listenForInstances() error = %v", err)
+	}
+	defer listener.Close()
+	go app.serveInstanceRequests(listener)
+
+	// A second GUI must not take over the socket of a running one
+	if second, err := listenForInstances(); err == nil {
+		second.Close()
+		t.Fatalf("listenForInstances() with an instance already listening succeeded")
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		reply   string
+		tab     string
+	}{
+		{name: "Focus", command: "focus", reply: "ok", tab: "Home"},
+		{name: "Tab", command: "tab storage", reply: "ok", tab: "Storage"},
+		{name: "Unknown tab", command: "tab nowhere", reply: `unknown tab "nowhere"`, tab: "Storage"},
+		{name: "Tab without a name", command: "tab", reply: "usage: tab <name>", tab: "Storage"},
+		{name: "Unknown command", command: "quit", reply: `unknown command "quit"`, tab: "Storage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := sendInstanceCommand(tt.command)
+			if err != nil || reply != tt.reply {
+				t.Errorf("sendInstanceCommand(%q) = %q, %v, want %q", tt.command, reply, err, tt.reply)
+			}
+			if got := app.MainTabs.Selected().Text; got != tt.tab {
+				t.Errorf("selected tab = %q, want %q", got, tt.tab)
+			}
+		})
+	}
+
+	activated, err := ActivateRunningInstance([]string{"home"})
+	if !activated || err != nil {
+		t.Errorf("ActivateRunningInstance() = %v, %v, want true, nil", activated, err)
+	}
+}
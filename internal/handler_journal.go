@@ -0,0 +1,399 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Steps of a journaled move, in the order they run.
+const (
+	moveStepCopy   = "copy"
+	moveStepVerify = "verify"
+	moveStepDelete = "delete"
+	moveStepLink   = "link"
+)
+
+// MoveJournalEntry A game data move that has started but not finished.
+// A kind of data that isn't being moved has empty paths.
+type MoveJournalEntry struct {
+	AppID         string    `json:"appid"`
+	Step          string    `json:"step"`
+	Started       time.Time `json:"started"`
+	FromCompatDir string    `json:"from_compat_dir"`
+	FromShaderDir string    `json:"from_shader_dir"`
+	ToCompatDir   string    `json:"to_compat_dir"`
+	ToShaderDir   string    `json:"to_shader_dir"`
+	// Whether the SSD held symlinks before the move, they're removed before copying.
+	CompatLinked bool `json:"compat_linked"`
+	ShaderLinked bool `json:"shader_linked"`
+	// Whether the destinations held data before the move, only new copies are discarded.
+	CompatExisted bool `json:"compat_existed"`
+	ShaderExisted bool `json:"shader_existed"`
+	// Whether the data was renamed rather than copied, then the destination is the only copy.
+	CompatRenamed bool `json:"compat_renamed"`
+	ShaderRenamed bool `json:"shader_renamed"`
+	// Whether to link the SSD to the destination once the source is gone.
+	CompatLink bool `json:"compat_link"`
+	ShaderLink bool `json:"shader_link"`
+}
+
+// One of the trees a move handles, along with its link on the SSD.
+type movePair struct {
+	from     string
+	to       string
+	steamDir string
+	existed  bool
+	linked   bool
+	link     bool
+	renamed  *bool
+}
+
+// Get the trees this move handles, skipping any kind of data that isn't being moved.
+func (e *MoveJournalEntry) pairs() []movePair {
+	var pairs []movePair
+	if e.FromCompatDir != "" {
+		pairs = append(pairs, movePair{from: e.FromCompatDir, to: e.ToCompatDir, steamDir: e.steamCompatDir(),
+			existed: e.CompatExisted, linked: e.CompatLinked, link: e.CompatLink, renamed: &e.CompatRenamed})
+	}
+	if e.FromShaderDir != "" {
+		pairs = append(pairs, movePair{from: e.FromShaderDir, to: e.ToShaderDir, steamDir: e.steamShaderDir(),
+			existed: e.ShaderExisted, linked: e.ShaderLinked, link: e.ShaderLink, renamed: &e.ShaderRenamed})
+	}
+	return pairs
+}
+
+var moveJournalMutex sync.Mutex
+
+func (e *MoveJournalEntry) steamCompatDir() string {
+	return filepath.Join(SteamCompatRoot, e.AppID)
+}
+
+func (e *MoveJournalEntry) steamShaderDir() string {
+	return filepath.Join(SteamShaderRoot, e.AppID)
+}
+
+// Read every entry in the move journal, keyed by appid.
+func readMoveJournal() (map[string]*MoveJournalEntry, error) {
+	entries := make(map[string]*MoveJournalEntry)
+	contents, err := os.ReadFile(MoveJournalPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(contents, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("error reading move journal: %w", err)
+	}
+	return entries, nil
+}
+
+// Replace the move journal, syncing it to disk so it survives a power loss.
+func writeMoveJournal(entries map[string]*MoveJournalEntry) error {
+	if len(entries) == 0 {
+		err := os.Remove(MoveJournalPath)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	contents, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempPath := MoveJournalPath + ".tmp"
+	file, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(contents)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	return os.Rename(tempPath, MoveJournalPath)
+}
+
+// Record the step a move is about to start.
+func recordMoveStep(entry *MoveJournalEntry, step string) error {
+	moveJournalMutex.Lock()
+	defer moveJournalMutex.Unlock()
+
+	entries, err := readMoveJournal()
+	if err != nil {
+		return err
+	}
+	entry.Step = step
+	entries[entry.AppID] = entry
+	err = writeMoveJournal(entries)
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to write move journal:", err)
+		return fmt.Errorf("error writing move journal: %w", err)
+	}
+	return nil
+}
+
+// Remove a move from the journal once it has finished or been rolled back.
+func finishMoveEntry(entry *MoveJournalEntry) error {
+	moveJournalMutex.Lock()
+	defer moveJournalMutex.Unlock()
+
+	entries, err := readMoveJournal()
+	if err != nil {
+		return err
+	}
+	delete(entries, entry.AppID)
+	err = writeMoveJournal(entries)
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to write move journal:", err)
+		return fmt.Errorf("error writing move journal: %w", err)
+	}
+	return nil
+}
+
+// Get the moves that didn't finish, oldest first.
+func getIncompleteMoves() ([]*MoveJournalEntry, error) {
+	moveJournalMutex.Lock()
+	entries, err := readMoveJournal()
+	moveJournalMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var moves []*MoveJournalEntry
+	for _, entry := range entries {
+		moves = append(moves, entry)
+	}
+	sort.Slice(moves, func(i, j int) bool {
+		return moves[i].Started.Before(moves[j].Started)
+	})
+	return moves, nil
+}
+
+// Run a journaled move from its current step to the end.
+// Until the source is deleted a failure rolls the move back, after that it stays in the journal to be resumed.
+func runMoveSteps(ctx context.Context, entry *MoveJournalEntry, tracker *copyTracker) error {
+	switch entry.Step {
+	case moveStepCopy:
+		// The source stays untouched, or is renamed as a whole, so a failure only has to clean up after itself
+		var err error
+		for _, pair := range entry.pairs() {
+			err = transferTree(ctx, entry, pair, tracker)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+			discardMove(entry)
+			if errors.Is(err, syscall.ENOSPC) {
+				err = fmt.Errorf("%w: %w", ErrInsufficientSpace, err)
+			}
+			return fmt.Errorf("error copying data for %s: %w", entry.AppID, err)
+		}
+		err = recordMoveStep(entry, moveStepVerify)
+		if err != nil {
+			return err
+		}
+		fallthrough
+	case moveStepVerify:
+		err := verifyMove(ctx, entry)
+		if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+			discardMove(entry)
+			return err
+		}
+		err = recordMoveStep(entry, moveStepDelete)
+		if err != nil {
+			return err
+		}
+		fallthrough
+	case moveStepDelete:
+		// From here on the move runs to completion, stopping part-way would leave the game without data.
+		ctx = context.Background()
+		for _, pair := range entry.pairs() {
+			CryoUtils.InfoLog.Println("Removing old " + pair.from)
+			err := os.RemoveAll(pair.from)
+			if err != nil {
+				CryoUtils.ErrorLog.Println(err)
+				return fmt.Errorf("error removing %s: %w", pair.from, err)
+			}
+			err = waitForDeletion(ctx, filepath.Dir(pair.from), filepath.Base(pair.from))
+			if err != nil {
+				CryoUtils.ErrorLog.Println(err)
+				return err
+			}
+		}
+		err := recordMoveStep(entry, moveStepLink)
+		if err != nil {
+			return err
+		}
+		fallthrough
+	case moveStepLink:
+		for _, pair := range entry.pairs() {
+			if !pair.link {
+				continue
+			}
+			CryoUtils.InfoLog.Println("Creating symlink to new path on SSD...")
+			err := replaceWithSymlink(pair.to, pair.steamDir)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown move step %q for %s", entry.Step, entry.AppID)
+	}
+	return finishMoveEntry(entry)
+}
+
+// Move one tree to its destination, renaming it when both are on the same filesystem and copying otherwise.
+func transferTree(ctx context.Context, entry *MoveJournalEntry, pair movePair, tracker *copyTracker) error {
+	if *pair.renamed {
+		if !doesFileExist(pair.from) && doesFileExist(pair.to) {
+			// Renamed before the move was interrupted
+			return tracker.skipTree(ctx, pair.to)
+		}
+		*pair.renamed = false
+	}
+	err := os.MkdirAll(filepath.Dir(pair.to), 0755)
+	if err != nil {
+		return err
+	}
+	if !canRenameTree(pair.from, pair.to) {
+		return copyTree(ctx, pair.from, pair.to, tracker)
+	}
+
+	// Record the rename before doing it, so a restart knows where the data went
+	*pair.renamed = true
+	err = recordMoveStep(entry, moveStepCopy)
+	if err != nil {
+		*pair.renamed = false
+		return err
+	}
+	err = os.Rename(pair.from, pair.to)
+	if err == nil {
+		CryoUtils.InfoLog.Println("Renamed", pair.from, "to", pair.to)
+		return tracker.skipTree(ctx, pair.to)
+	}
+	CryoUtils.ErrorLog.Println("Unable to rename, copying instead:", err)
+	*pair.renamed = false
+	err = recordMoveStep(entry, moveStepCopy)
+	if err != nil {
+		return err
+	}
+	return copyTree(ctx, pair.from, pair.to, tracker)
+}
+
+// Point link at target, replacing a symlink left by an earlier attempt.
+func replaceWithSymlink(target string, link string) error {
+	if isSymbolicLink(link) {
+		_ = os.Remove(link)
+	}
+	err := os.Symlink(target, link)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		return fmt.Errorf("error linking %s: %w", link, err)
+	}
+	return nil
+}
+
+// Throw away the copies a move made and point the SSD back at the untouched source.
+func discardMove(entry *MoveJournalEntry) {
+	CryoUtils.InfoLog.Println("Discarding partial copy of", entry.AppID)
+	for _, pair := range entry.pairs() {
+		if *pair.renamed {
+			// Renamed data is the only copy, put it back
+			if !doesFileExist(pair.from) {
+				_ = os.Rename(pair.to, pair.from)
+			}
+			continue
+		}
+		if !pair.existed {
+			_ = os.RemoveAll(pair.to)
+		}
+	}
+	for _, pair := range entry.pairs() {
+		if pair.linked {
+			_ = replaceWithSymlink(pair.from, pair.steamDir)
+		}
+	}
+	err := finishMoveEntry(entry)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
+}
+
+// Finish an incomplete move from the step it stopped at.
+func resumeMove(ctx context.Context, entry *MoveJournalEntry) error {
+	CryoUtils.InfoLog.Println("Resuming move of", entry.AppID, "at step", entry.Step)
+	if isGameRunning(entry.AppID) {
+		return fmt.Errorf("cannot move data for %s: %w", entry.AppID, ErrSteamRunning)
+	}
+	return runMoveSteps(ctx, entry, nil)
+}
+
+// Undo an incomplete move, leaving the game's data where it was before the move started.
+func rollBackMove(ctx context.Context, entry *MoveJournalEntry) error {
+	CryoUtils.InfoLog.Println("Rolling back move of", entry.AppID, "from step", entry.Step)
+	if isGameRunning(entry.AppID) {
+		return fmt.Errorf("cannot move data for %s: %w", entry.AppID, ErrSteamRunning)
+	}
+	switch entry.Step {
+	case moveStepCopy, moveStepVerify:
+		// The source is untouched
+	case moveStepDelete, moveStepLink:
+		// The source is partly or fully gone, bring it back from the finished copy.
+		// Drop any new links first, or the copy would write through them into itself.
+		for _, pair := range entry.pairs() {
+			if isSymbolicLink(pair.steamDir) {
+				_ = os.Remove(pair.steamDir)
+			}
+		}
+		// Renamed data is put back by discardMove
+		for _, pair := range entry.pairs() {
+			if *pair.renamed {
+				continue
+			}
+			err := copyTree(ctx, pair.to, pair.from, nil)
+			if err != nil {
+				CryoUtils.ErrorLog.Println(err)
+				return fmt.Errorf("error restoring data for %s: %w", entry.AppID, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown move step %q for %s", entry.Step, entry.AppID)
+	}
+	discardMove(entry)
+	return nil
+}
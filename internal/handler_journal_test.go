@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The data of one game, by path relative to a drive's Steam folder.
+var journalTestFiles = map[string]string{
+	filepath.Join("compatdata", "620", "pfx", "save.dat"):                 "progress",
+	filepath.Join("shadercache", "620", "fozpipelinesv6", "steamapp.foz"): "shaders",
+}
+
+func writeJournalTestFiles(t *testing.T, root string, only string) {
+	for path, contents := range journalTestFiles {
+		if only != "" && filepath.Dir(filepath.Dir(filepath.Dir(path))) != only {
+			continue
+		}
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Lay out a move of 620 from the SSD to a card as it would be found after stopping just before step.
+func setupInterruptedMove(t *testing.T, step string, renamed bool) (*MoveJournalEntry, string, string) {
+	root := t.TempDir()
+	ssd, card := filepath.Join(root, "ssd"), filepath.Join(root, "card")
+	oldCompat, oldShader, oldJournal, oldSettings := SteamCompatRoot, SteamShaderRoot, MoveJournalPath, SettingsPath
+	SteamCompatRoot, SteamShaderRoot = filepath.Join(ssd, "compatdata"), filepath.Join(ssd, "shadercache")
+	MoveJournalPath, SettingsPath = filepath.Join(root, "move_journal.json"), filepath.Join(root, "settings.json")
+	t.Cleanup(func() {
+		SteamCompatRoot, SteamShaderRoot, MoveJournalPath, SettingsPath = oldCompat, oldShader, oldJournal, oldSettings
+	})
+
+	entry := &MoveJournalEntry{
+		AppID:         "620",
+		FromCompatDir: filepath.Join(ssd, "compatdata", "620"),
+		FromShaderDir: filepath.Join(ssd, "shadercache", "620"),
+		ToCompatDir:   filepath.Join(card, "compatdata", "620"),
+		ToShaderDir:   filepath.Join(card, "shadercache", "620"),
+		CompatRenamed: renamed,
+		ShaderRenamed: renamed,
+		CompatLink:    true,
+		ShaderLink:    true,
+	}
+
+	// The SSD's data roots are always there, only the game's folders come and go
+	for _, dir := range []string{SteamCompatRoot, SteamShaderRoot} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	switch {
+	case renamed:
+		// Both trees were renamed as a whole before the copy step was done
+		writeJournalTestFiles(t, card, "")
+	case step == moveStepCopy:
+		writeJournalTestFiles(t, ssd, "")
+		writeJournalTestFiles(t, card, "compatdata")
+	case step == moveStepVerify:
+		writeJournalTestFiles(t, ssd, "")
+		writeJournalTestFiles(t, card, "")
+	case step == moveStepDelete:
+		writeJournalTestFiles(t, ssd, "shadercache")
+		writeJournalTestFiles(t, card, "")
+	case step == moveStepLink:
+		writeJournalTestFiles(t, card, "")
+	}
+	if step == moveStepLink {
+		if err := os.Symlink(entry.ToCompatDir, entry.FromCompatDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := recordMoveStep(entry, step); err != nil {
+		t.Fatal(err)
+	}
+	return entry, ssd, card
+}
+
+// Check that every file of the game is found under root, and that the journal is empty.
+func checkJournalTestFiles(t *testing.T, root string) {
+	for path, want := range journalTestFiles {
+		got, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v, want %q", path, got, err, want)
+		}
+	}
+	moves, err := getIncompleteMoves()
+	if err != nil || len(moves) != 0 {
+		t.Errorf("getIncompleteMoves() = %v, %v, want none", moves, err)
+	}
+}
+
+func TestResumeAndRollBackMove(t *testing.T) {
+	steps := []string{moveStepCopy, moveStepVerify, moveStepDelete, moveStepLink}
+	kinds := []struct {
+		name    string
+		renamed bool
+	}{
+		{name: "Copied", renamed: false},
+		{name: "Renamed", renamed: true},
+	}
+
+	for _, kind := range kinds {
+		for _, step := range steps {
+			t.Run(kind.name+" resume from "+step, func(t *testing.T) {
+				entry, ssd, card := setupInterruptedMove(t, step, kind.renamed)
+				if err := resumeMove(context.Background(), entry); err != nil {
+					t.Fatalf("resumeMove() error = %v", err)
+				}
+				checkJournalTestFiles(t, card)
+				for _, kind := range []string{"compatdata", "shadercache"} {
+					link := filepath.Join(ssd, kind, "620")
+					target, err := os.Readlink(link)
+					if err != nil || target != filepath.Join(card, kind, "620") {
+						t.Errorf("%s links to %q, %v, want the card", link, target, err)
+					}
+				}
+			})
+
+			t.Run(kind.name+" roll back from "+step, func(t *testing.T) {
+				entry, ssd, card := setupInterruptedMove(t, step, kind.renamed)
+				if err := rollBackMove(context.Background(), entry); err != nil {
+					t.Fatalf("rollBackMove() error = %v", err)
+				}
+				checkJournalTestFiles(t, ssd)
+				for _, kind := range []string{"compatdata", "shadercache"} {
+					if isSymbolicLink(filepath.Join(ssd, kind, "620")) {
+						t.Errorf("%s is still a link", filepath.Join(ssd, kind, "620"))
+					}
+					if doesFileExist(filepath.Join(card, kind, "620")) {
+						t.Errorf("%s was left behind", filepath.Join(card, kind, "620"))
+					}
+				}
+			})
+		}
+	}
+}
@@ -17,9 +17,14 @@
 package internal
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/andygrunwald/vdf"
 )
@@ -27,8 +32,34 @@ import (
 type Library struct {
 	Path           string
 	InstalledGames []int
+	// The manifest of each game in the library, by appid
+	Manifests map[int]AppManifest
 }
 
+// AppManifest What Steam records about a game in its library's appmanifest_<appid>.acf.
+type AppManifest struct {
+	AppID       int
+	Name        string
+	InstallDir  string
+	SizeOnDisk  int64
+	LastUpdated time.Time
+	StateFlags  int
+}
+
+// StateFullyInstalled The StateFlags bit Steam sets once a game is fully installed.
+const StateFullyInstalled = 4
+
+// IsInstalled Whether Steam considers the game fully installed, even if an update is pending.
+func (m AppManifest) IsInstalled() bool {
+	return m.StateFlags&StateFullyInstalled != 0
+}
+
+// Names of the games found in the library manifests, kept from the last findDataFolders.
+var manifestNames = struct {
+	sync.Mutex
+	names map[int]string
+}{}
+
 func (lib *Library) listGames() {
 	for _, game := range lib.InstalledGames {
 		CryoUtils.InfoLog.Println(game)
@@ -55,9 +86,123 @@ func findDataFolders() ([]Library, error) {
 		}
 	}
 
+	manifestNames.Lock()
+	defer manifestNames.Unlock()
+	manifestNames.names = make(map[int]string)
+	for _, library := range libraries {
+		for appID, manifest := range library.Manifests {
+			if manifest.Name != "" {
+				manifestNames.names[appID] = manifest.Name
+			}
+		}
+	}
 	return libraries, nil
 }
 
+// Get the manifest of every game in every library, by appid.
+func getAppManifests() (map[int]AppManifest, error) {
+	libraries, err := findDataFolders()
+	if err != nil {
+		return nil, err
+	}
+	manifests := make(map[int]AppManifest)
+	for _, library := range libraries {
+		for appID, manifest := range library.Manifests {
+			manifests[appID] = manifest
+		}
+	}
+	return manifests, nil
+}
+
+// Get a game's name from the library manifests, falling back to appinfo.vdf and then the Steam API for games
+// without one.
+// Relies on findDataFolders having run.
+func getGameName(ctx context.Context, appID int) string {
+	manifestNames.Lock()
+	name, ok := manifestNames.names[appID]
+	manifestNames.Unlock()
+	if ok {
+		return name
+	}
+	if shortcut, ok := getShortcuts()[appID]; ok {
+		return shortcut.Name
+	}
+	// Steam still knows the names of uninstalled games it has seen, even offline
+	if info, ok := getAppInfo(appID); ok && info.Name != "" {
+		return info.Name
+	}
+	return getAPIGameName(ctx, appID)
+}
+
+// Read every appmanifest_<appid>.acf in a library's steamapps folder, skipping any that can't be parsed.
+func readAppManifests(steamApps string) (map[int]AppManifest, error) {
+	paths, err := filepath.Glob(filepath.Join(steamApps, "appmanifest_*.acf"))
+	if err != nil {
+		return nil, err
+	}
+	manifests := make(map[int]AppManifest)
+	for _, path := range paths {
+		manifest, err := parseAppManifest(path)
+		if err != nil {
+			CryoUtils.ErrorLog.Println("Skipping manifest", path+":", err)
+			continue
+		}
+		manifests[manifest.AppID] = manifest
+	}
+	return manifests, nil
+}
+
+// Parse a single appmanifest_<appid>.acf.
+func parseAppManifest(path string) (AppManifest, error) {
+	var manifest AppManifest
+	f, err := os.Open(path)
+	if err != nil {
+		return manifest, err
+	}
+	defer f.Close()
+
+	m, err := vdf.NewParser(f).Parse()
+	if err != nil {
+		return manifest, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	state, ok := getVDFValue(m, "AppState").(map[string]interface{})
+	if !ok {
+		return manifest, fmt.Errorf("%s has no AppState", path)
+	}
+
+	// Steam isn't consistent about the case of keys, so look every key up regardless of case
+	value := func(key string) string {
+		str, _ := getVDFValue(state, key).(string)
+		return str
+	}
+	manifest.AppID, err = strconv.Atoi(value("appid"))
+	if err != nil {
+		return manifest, fmt.Errorf("%s has no valid appid: %w", path, err)
+	}
+	manifest.Name = value("name")
+	manifest.InstallDir = value("installdir")
+	manifest.SizeOnDisk, _ = strconv.ParseInt(value("SizeOnDisk"), 10, 64)
+	manifest.StateFlags, _ = strconv.Atoi(value("StateFlags"))
+	lastUpdated, err := strconv.ParseInt(value("LastUpdated"), 10, 64)
+	if err == nil && lastUpdated != 0 {
+		manifest.LastUpdated = time.Unix(lastUpdated, 0)
+	}
+	return manifest, nil
+}
+
+// Get a value from a parsed VDF map, ignoring the case of the key.
+func getVDFValue(m map[string]interface{}, key string) interface{} {
+	if value, ok := m[key]; ok {
+		return value
+	}
+	for k, value := range m {
+		if strings.EqualFold(k, key) {
+			return value
+		}
+	}
+	return nil
+}
+
 // Parse the specified VDF file and return a slice of libraries.
 func parseVDF(file string) ([]Library, error) {
 	var libraries []Library
@@ -75,14 +220,29 @@ func parseVDF(file string) ([]Library, error) {
 
 	for _, library := range m["libraryfolders"].(map[string]interface{}) {
 		var installedGames []int
+		path := library.(map[string]interface{})["path"].(string)
 
-		for game := range library.(map[string]interface{})["apps"].(map[string]interface{}) {
-			intGame, _ := strconv.Atoi(game)
-			installedGames = append(installedGames, intGame)
+		// The manifests say which games are really installed, the apps list includes half-finished installs
+		manifests, err := readAppManifests(filepath.Join(path, "steamapps"))
+		if err != nil {
+			CryoUtils.ErrorLog.Println("Unable to read manifests in", path+":", err)
+		}
+		if len(manifests) != 0 {
+			for appID, manifest := range manifests {
+				if manifest.IsInstalled() {
+					installedGames = append(installedGames, appID)
+				}
+			}
+		} else {
+			for game := range library.(map[string]interface{})["apps"].(map[string]interface{}) {
+				intGame, _ := strconv.Atoi(game)
+				installedGames = append(installedGames, intGame)
+			}
 		}
 		newLib := Library{
-			Path:           library.(map[string]interface{})["path"].(string),
+			Path:           path,
 			InstalledGames: installedGames,
+			Manifests:      manifests,
 		}
 		libraries = append(libraries, newLib)
 	}
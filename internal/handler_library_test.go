@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseAppManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := `"AppState"
+{
+	"appid"		"620"
+	"Universe"		"1"
+	"name"		"Portal 2"
+	"StateFlags"		"6"
+	"installdir"		"Portal 2"
+	"LastUpdated"		"1700000000"
+	"SizeOnDisk"		"12937021254"
+	"InstalledDepots"
+	{
+		"621"
+		{
+			"manifest"		"1234"
+		}
+	}
+}
+`
+	_ = os.WriteFile(filepath.Join(dir, "appmanifest_620.acf"), []byte(manifest), 0644)
+	// Key case varies between manifests, and an update can be pending on an installed game
+	_ = os.WriteFile(filepath.Join(dir, "appmanifest_400.acf"),
+		[]byte("\"appstate\"\n{\n\t\"appid\"\t\"400\"\n\t\"name\"\t\"Portal\"\n\t\"stateflags\"\t\"1030\"\n}\n"), 0644)
+	_ = os.WriteFile(filepath.Join(dir, "appmanifest_1.acf"), []byte("not a manifest"), 0644)
+
+	manifests, err := readAppManifests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 2 {
+		t.Fatalf("readAppManifests() found %d manifests, want 2", len(manifests))
+	}
+
+	want := AppManifest{AppID: 620, Name: "Portal 2", InstallDir: "Portal 2", SizeOnDisk: 12937021254,
+		LastUpdated: time.Unix(1700000000, 0), StateFlags: 6}
+	if got := manifests[620]; got != want {
+		t.Errorf("parseAppManifest() = %+v, want %+v", got, want)
+	}
+	if !manifests[620].IsInstalled() {
+		t.Error("IsInstalled() = false for a fully installed game")
+	}
+	if got := manifests[400]; got.Name != "Portal" || !got.IsInstalled() {
+		t.Errorf("parseAppManifest() = %+v, want an installed Portal", got)
+	}
+}
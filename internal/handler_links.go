@@ -0,0 +1,198 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// States an entry in SteamCompatRoot or SteamShaderRoot can be in.
+const (
+	LinkStatusDirectory = "directory"
+	LinkStatusValid     = "valid link"
+	LinkStatusDangling  = "dangling link"
+	LinkStatusUnmounted = "link to unmounted card"
+)
+
+// Ways to repair a broken link.
+const (
+	LinkRepairRecreate = "recreate"
+	LinkRepairRepoint  = "repoint"
+	LinkRepairRemove   = "remove"
+)
+
+// LinkRepairs Every way to repair a broken link
+var LinkRepairs = []string{LinkRepairRecreate, LinkRepairRepoint, LinkRepairRemove}
+
+// DataLink An entry in SteamCompatRoot or SteamShaderRoot
+type DataLink struct {
+	AppID  string `json:"appid"`
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Target string `json:"target,omitempty"`
+	// Where the same data was found on a card that's mounted now, if anywhere
+	Candidate string `json:"candidate,omitempty"`
+}
+
+// IsBroken Whether the entry is a link that Steam can't follow.
+func (l DataLink) IsBroken() bool {
+	return l.Status == LinkStatusDangling || l.Status == LinkStatusUnmounted
+}
+
+// ScanDataLinks Classify every entry in SteamCompatRoot and SteamShaderRoot.
+func ScanDataLinks() ([]DataLink, error) {
+	drives, err := getListOfAttachedDrives()
+	if err != nil {
+		return nil, err
+	}
+
+	var links []DataLink
+	roots := map[string]string{SteamCompatRoot: ExternalCompatRoot, SteamShaderRoot: ExternalShaderRoot}
+	for root, externalRoot := range roots {
+		entries, err := os.ReadDir(root)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+			return nil, err
+		}
+		for _, entry := range entries {
+			link, ok := classifyDataLink(filepath.Join(root, entry.Name()), drives)
+			if !ok {
+				continue
+			}
+			if link.IsBroken() {
+				link.Candidate = findLinkCandidate(link.AppID, externalRoot, drives)
+			}
+			links = append(links, link)
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Path < links[j].Path
+	})
+	return links, nil
+}
+
+// Work out what an entry in one of the SSD data roots is, false if it's neither a directory nor a link.
+func classifyDataLink(path string, drives []string) (DataLink, bool) {
+	link := DataLink{AppID: filepath.Base(path), Path: path}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return link, false
+	}
+	if info.IsDir() {
+		link.Status = LinkStatusDirectory
+		return link, true
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return link, false
+	}
+
+	link.Target, err = os.Readlink(path)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		return link, false
+	}
+	if !filepath.IsAbs(link.Target) {
+		link.Target = filepath.Join(filepath.Dir(path), link.Target)
+	}
+
+	switch {
+	case doesFileExist(link.Target):
+		link.Status = LinkStatusValid
+	case strings.HasPrefix(link.Target, MountDirectory+string(filepath.Separator)) &&
+		!isOnMountedDrive(link.Target, drives):
+		link.Status = LinkStatusUnmounted
+	default:
+		link.Status = LinkStatusDangling
+	}
+	return link, true
+}
+
+// Check whether a path is on one of the currently mounted external drives.
+func isOnMountedDrive(path string, drives []string) bool {
+	for _, drive := range drives {
+		if drive != SteamDataRoot && strings.HasPrefix(path, drive+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Look for a game's data on the attached cards, returning the first match.
+func findLinkCandidate(appID string, externalRoot string, drives []string) string {
+	for _, drive := range drives {
+		if drive == SteamDataRoot {
+			continue
+		}
+		candidate := filepath.Join(drive, externalRoot, appID)
+		info, err := os.Stat(candidate)
+		if err == nil && info.IsDir() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// RepairDataLink Repair a broken link by recreating an empty directory, re-pointing it, or removing it.
+func RepairDataLink(link DataLink, repair string) error {
+	// Only ever touch broken links, a real directory or a working link still leads to game data
+	current, ok := classifyDataLink(link.Path, nil)
+	if !ok || current.Status == LinkStatusDirectory {
+		return fmt.Errorf("%w: %s is not a link", ErrInvalidArgument, link.Path)
+	}
+	if !current.IsBroken() {
+		return fmt.Errorf("%w: %s still points to its data", ErrInvalidArgument, link.Path)
+	}
+
+	switch repair {
+	case LinkRepairRecreate:
+		CryoUtils.InfoLog.Println("Replacing", link.Path, "with an empty directory")
+		err := os.Remove(link.Path)
+		if err != nil {
+			return fmt.Errorf("error removing %s: %w", link.Path, err)
+		}
+		err = os.Mkdir(link.Path, 0755)
+		if err != nil {
+			return fmt.Errorf("error creating %s: %w", link.Path, err)
+		}
+	case LinkRepairRepoint:
+		if link.Candidate == "" {
+			return fmt.Errorf("%w: no copy of %s was found on the attached drives", ErrInvalidArgument, link.AppID)
+		}
+		CryoUtils.InfoLog.Println("Re-pointing", link.Path, "to", link.Candidate)
+		err := replaceWithSymlink(link.Candidate, link.Path)
+		if err != nil {
+			return err
+		}
+	case LinkRepairRemove:
+		CryoUtils.InfoLog.Println("Removing", link.Path)
+		err := os.Remove(link.Path)
+		if err != nil {
+			return fmt.Errorf("error removing %s: %w", link.Path, err)
+		}
+	default:
+		return fmt.Errorf("%w: repair must be one of %v", ErrInvalidArgument, LinkRepairs)
+	}
+	return nil
+}
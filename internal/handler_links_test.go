@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClassifyDataLink(t *testing.T) {
+	root := t.TempDir()
+	oldMountDirectory := MountDirectory
+	MountDirectory = filepath.Join(root, "media")
+	defer func() { MountDirectory = oldMountDirectory }()
+
+	card := filepath.Join(MountDirectory, "deck", "card")
+	_ = os.MkdirAll(filepath.Join(card, "compatdata", "100"), 0755)
+	_ = os.MkdirAll(filepath.Join(root, "compat", "400"), 0755)
+	_ = os.Symlink(filepath.Join(card, "compatdata", "100"), filepath.Join(root, "compat", "100"))
+	_ = os.Symlink(filepath.Join(card, "compatdata", "200"), filepath.Join(root, "compat", "200"))
+	_ = os.Symlink(filepath.Join(MountDirectory, "deck", "gone", "compatdata", "300"),
+		filepath.Join(root, "compat", "300"))
+	drives := []string{SteamDataRoot, card}
+
+	tests := []struct {
+		name  string
+		appID string
+		want  string
+	}{
+		{name: "Directory", appID: "400", want: LinkStatusDirectory},
+		{name: "Valid link", appID: "100", want: LinkStatusValid},
+		{name: "Dangling link", appID: "200", want: LinkStatusDangling},
+		{name: "Unmounted card", appID: "300", want: LinkStatusUnmounted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := classifyDataLink(filepath.Join(root, "compat", tt.appID), drives)
+			if !ok || got.Status != tt.want {
+				t.Errorf("classifyDataLink() = %v, %v, want %v", got.Status, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepairDataLinkRefusesWorkingLinks(t *testing.T) {
+	root := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(root, "card", "100"), 0755)
+	_ = os.MkdirAll(filepath.Join(root, "compat", "200"), 0755)
+	_ = os.Symlink(filepath.Join(root, "card", "100"), filepath.Join(root, "compat", "100"))
+
+	tests := []struct {
+		name  string
+		appID string
+	}{
+		{name: "Valid link", appID: "100"},
+		{name: "Directory", appID: "200"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(root, "compat", tt.appID)
+			err := RepairDataLink(DataLink{AppID: tt.appID, Path: path}, LinkRepairRemove)
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("RepairDataLink() error = %v, want %v", err, ErrInvalidArgument)
+			}
+			if _, err := os.Lstat(path); err != nil {
+				t.Errorf("RepairDataLink() removed %s", path)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Names of the operations that can hold the operation lock.
+const (
+	OperationSwap     = "swap resize"
+	OperationTweaks   = "tweak change"
+	OperationGameData = "game data change"
+)
+
+// LockHolder Information about the process currently holding the operation lock
+type LockHolder struct {
+	PID       int               `json:"pid"`
+	Operation string            `json:"operation"`
+	Started   time.Time         `json:"started"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+type OperationLock struct {
+	file   *os.File
+	holder LockHolder
+}
+
+// The lock held by this process, details recorded during the operation are written to it.
+var currentLock *OperationLock
+var currentLockMutex sync.Mutex
+
+// How many times to retry a lock attempt, short status probes from the GUI can briefly hold the file.
+var lockAttempts = 3
+var lockRetryDelay = 200 * time.Millisecond
+
+// Get the home directory of the user who ran sudo, or the current user's when not run through sudo.
+func getInvokingUserHome() string {
+	if os.Geteuid() == 0 {
+		if sudoUser, err := user.Lookup(os.Getenv("SUDO_USER")); err == nil && sudoUser.HomeDir != "" {
+			return sudoUser.HomeDir
+		}
+	}
+	return HomeDirectory
+}
+
+// Hand a path created under sudo back to the user who ran it, so the GUI can still use it afterwards.
+func chownToInvokingUser(path string) {
+	uid, uidErr := strconv.Atoi(os.Getenv("SUDO_UID"))
+	gid, gidErr := strconv.Atoi(os.Getenv("SUDO_GID"))
+	if os.Geteuid() != 0 || uidErr != nil || gidErr != nil {
+		return
+	}
+	_ = os.Chown(path, uid, gid)
+}
+
+// Open the lock file, creating it with permissions that let both the GUI user and root use it.
+func openLockFile() (*os.File, error) {
+	directory := filepath.Dir(OperationLockPath)
+	_ = os.MkdirAll(directory, 0777)
+	chownToInvokingUser(directory)
+	file, err := os.OpenFile(OperationLockPath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	chownToInvokingUser(OperationLockPath)
+	// Ignore the error, only the owner can change the mode and that's fine.
+	_ = file.Chmod(0666)
+	return file, nil
+}
+
+// Read the holder information written to the lock file, nil if the file is empty.
+func readLockHolder(file *os.File) (*LockHolder, error) {
+	_, err := file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) == 0 {
+		return nil, nil
+	}
+	var holder LockHolder
+	err = json.Unmarshal(contents, &holder)
+	if err != nil {
+		return nil, err
+	}
+	return &holder, nil
+}
+
+// Take the system-wide operation lock, failing if another operation is already running.
+func acquireOperationLock(operation string) (*OperationLock, error) {
+	file, err := openLockFile()
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to open lock file:", err)
+		return nil, fmt.Errorf("error opening lock file: %w", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, unix.EWOULDBLOCK) || attempt >= lockAttempts {
+			holder, _ := readLockHolder(file)
+			file.Close()
+			if !errors.Is(err, unix.EWOULDBLOCK) {
+				CryoUtils.ErrorLog.Println("Unable to lock", OperationLockPath, err)
+				return nil, fmt.Errorf("error locking %s: %w", OperationLockPath, err)
+			}
+			return nil, &LockHeldError{Operation: operation, Holder: holder}
+		}
+		time.Sleep(lockRetryDelay)
+	}
+
+	// Anything left in the file now belongs to a process that died without releasing the lock.
+	stale, _ := readLockHolder(file)
+	if stale != nil && stale.PID != 0 {
+		CryoUtils.InfoLog.Println("Found stale lock from process", stale.PID, "("+stale.Operation+
+			"), taking over.")
+	}
+
+	lock := &OperationLock{
+		file: file,
+		holder: LockHolder{
+			PID:       os.Getpid(),
+			Operation: operation,
+			Started:   time.Now(),
+		},
+	}
+	err = lock.writeHolder()
+	if err != nil {
+		lock.release()
+		return nil, err
+	}
+	currentLockMutex.Lock()
+	currentLock = lock
+	currentLockMutex.Unlock()
+	CryoUtils.InfoLog.Println("Acquired operation lock for", operation)
+	return lock, nil
+}
+
+// Write the holder information to the lock file so other processes can report it.
+func (l *OperationLock) writeHolder() error {
+	contents, err := json.Marshal(l.holder)
+	if err != nil {
+		return err
+	}
+	err = l.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = l.file.WriteAt(contents, 0)
+	return err
+}
+
+// Clear the holder information and drop the lock.
+func (l *OperationLock) release() {
+	currentLockMutex.Lock()
+	if currentLock == l {
+		currentLock = nil
+	}
+	currentLockMutex.Unlock()
+	_ = l.file.Truncate(0)
+	_ = unix.Flock(int(l.file.Fd()), unix.LOCK_UN)
+	l.file.Close()
+	CryoUtils.InfoLog.Println("Released operation lock for", l.holder.Operation)
+}
+
+// Record a detail of the running operation, so it can be resumed or rolled back if the process dies.
+// Does nothing if this process doesn't hold the lock.
+func recordOperationDetail(key string, value string) {
+	currentLockMutex.Lock()
+	defer currentLockMutex.Unlock()
+	if currentLock == nil {
+		return
+	}
+	if currentLock.holder.Details == nil {
+		currentLock.holder.Details = make(map[string]string)
+	}
+	currentLock.holder.Details[key] = value
+	err := currentLock.writeHolder()
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to record operation detail:", err)
+	}
+}
+
+// Get a description of the operation this process is running, empty if there is none.
+func getCurrentOperation() string {
+	currentLockMutex.Lock()
+	defer currentLockMutex.Unlock()
+	if currentLock == nil {
+		return ""
+	}
+	return describeOperation(&currentLock.holder)
+}
+
+// Describe an operation and its details for logs and reports.
+func describeOperation(holder *LockHolder) string {
+	description := holder.Operation
+	keys := make([]string, 0, len(holder.Details))
+	for key := range holder.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		description += " " + key + "=" + holder.Details[key]
+	}
+	return description
+}
+
+// Get the record left behind by an operation that didn't release the lock, nil if there is none.
+func getInterruptedOperation() (*LockHolder, error) {
+	file, err := os.Open(OperationLockPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	err = unix.Flock(int(file.Fd()), unix.LOCK_SH|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		// The operation is still running.
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer unix.Flock(int(file.Fd()), unix.LOCK_UN)
+	return readLockHolder(file)
+}
+
+// Forget an interrupted operation the user chose not to act on.
+func clearInterruptedOperation() error {
+	file, err := openLockFile()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if err != nil {
+		return fmt.Errorf("error locking %s: %w", OperationLockPath, err)
+	}
+	defer unix.Flock(int(file.Fd()), unix.LOCK_UN)
+	return file.Truncate(0)
+}
+
+// Get the current holder of the operation lock, nil if nobody holds it.
+func getLockHolder() (*LockHolder, error) {
+	file, err := os.Open(OperationLockPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	err = unix.Flock(int(file.Fd()), unix.LOCK_SH|unix.LOCK_NB)
+	if err == nil {
+		// Nobody holds the lock, any contents are stale.
+		_ = unix.Flock(int(file.Fd()), unix.LOCK_UN)
+		return nil, nil
+	}
+	if !errors.Is(err, unix.EWOULDBLOCK) {
+		return nil, err
+	}
+
+	holder, err := readLockHolder(file)
+	if err != nil {
+		return nil, err
+	}
+	if holder == nil {
+		// The holder hasn't written its details yet.
+		holder = &LockHolder{Operation: "unknown operation"}
+	}
+	return holder, nil
+}
+
+// WithOperationLock Run the provided function while holding the system-wide operation lock.
+func WithOperationLock(operation string, fn func() error) error {
+	lock, err := acquireOperationLock(operation)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	return fn()
+}
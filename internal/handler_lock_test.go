@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Point the operation lock at a temporary file for the length of a test.
+func useTempLock(t *testing.T) {
+	oldPath, oldAttempts := OperationLockPath, lockAttempts
+	OperationLockPath = filepath.Join(t.TempDir(), "cryoutilities.lock")
+	lockAttempts = 1
+	t.Cleanup(func() {
+		OperationLockPath, lockAttempts = oldPath, oldAttempts
+	})
+}
+
+func TestAcquireOperationLock(t *testing.T) {
+	useTempLock(t)
+
+	lock, err := acquireOperationLock(OperationSwap)
+	if err != nil {
+		t.Fatalf("acquireOperationLock() error = %v", err)
+	}
+	holder, err := getLockHolder()
+	if err != nil || holder == nil || holder.PID != os.Getpid() || holder.Operation != OperationSwap {
+		t.Errorf("getLockHolder() = %+v, %v, want %s held by %d", holder, err, OperationSwap, os.Getpid())
+	}
+
+	lock.release()
+	holder, err = getLockHolder()
+	if err != nil || holder != nil {
+		t.Errorf("getLockHolder() after release = %+v, %v, want nil", holder, err)
+	}
+}
+
+func TestAcquireOperationLockHeld(t *testing.T) {
+	useTempLock(t)
+
+	lock, err := acquireOperationLock(OperationGameData)
+	if err != nil {
+		t.Fatalf("acquireOperationLock() error = %v", err)
+	}
+	defer lock.release()
+
+	// Each acquire opens the file again, so a second one conflicts like another process would
+	_, err = acquireOperationLock(OperationTweaks)
+	var held *LockHeldError
+	if !errors.As(err, &held) || !errors.Is(err, ErrOperationLocked) {
+		t.Fatalf("acquireOperationLock() error = %v, want a LockHeldError", err)
+	}
+	if held.Operation != OperationTweaks {
+		t.Errorf("LockHeldError.Operation = %q, want %q", held.Operation, OperationTweaks)
+	}
+	if held.Holder == nil || held.Holder.PID != os.Getpid() || held.Holder.Operation != OperationGameData {
+		t.Errorf("LockHeldError.Holder = %+v, want %s held by %d", held.Holder, OperationGameData, os.Getpid())
+	}
+}
+
+func TestAcquireOperationLockStale(t *testing.T) {
+	useTempLock(t)
+
+	stale := `{"pid":999999,"operation":"swap resize","started":"2023-01-01T00:00:00Z","details":{"size":"8"}}`
+	if err := os.WriteFile(OperationLockPath, []byte(stale), 0666); err != nil {
+		t.Fatal(err)
+	}
+	interrupted, err := getInterruptedOperation()
+	if err != nil || interrupted == nil || interrupted.PID != 999999 || interrupted.Details["size"] != "8" {
+		t.Fatalf("getInterruptedOperation() = %+v, %v, want the stale swap resize", interrupted, err)
+	}
+
+	lock, err := acquireOperationLock(OperationGameData)
+	if err != nil {
+		t.Fatalf("acquireOperationLock() error = %v", err)
+	}
+	defer lock.release()
+	holder, err := getLockHolder()
+	if err != nil || holder == nil || holder.PID != os.Getpid() || holder.Details != nil {
+		t.Errorf("getLockHolder() = %+v, %v, want a fresh lock held by %d", holder, err, os.Getpid())
+	}
+}
+
+func TestRecordOperationDetail(t *testing.T) {
+	useTempLock(t)
+
+	// Nothing is recorded without the lock
+	recordOperationDetail("size", "4")
+	if got := getCurrentOperation(); got != "" {
+		t.Errorf("getCurrentOperation() without the lock = %q, want empty", got)
+	}
+
+	err := WithOperationLock(OperationSwap, func() error {
+		recordOperationDetail("size", "8")
+		recordOperationDetail("previous_size", "4")
+		if got, want := getCurrentOperation(), "swap resize previous_size=4 size=8"; got != want {
+			t.Errorf("getCurrentOperation() = %q, want %q", got, want)
+		}
+		holder, err := getLockHolder()
+		if err != nil || holder == nil || holder.Details["size"] != "8" || holder.Details["previous_size"] != "4" {
+			t.Errorf("getLockHolder() = %+v, %v, want the recorded details", holder, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithOperationLock() error = %v", err)
+	}
+	if got := getCurrentOperation(); got != "" {
+		t.Errorf("getCurrentOperation() after release = %q, want empty", got)
+	}
+}
@@ -0,0 +1,294 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DataTypeCompat Move only a game's compatdata.
+const DataTypeCompat = "compatdata"
+
+// DataTypeShader Move only a game's shadercache.
+const DataTypeShader = "shadercache"
+
+// DataTypeBoth Move a game's compatdata and shadercache.
+const DataTypeBoth = "both"
+
+// DataTypes The kinds of data a game can be moved with.
+var DataTypes = []string{DataTypeBoth, DataTypeCompat, DataTypeShader}
+
+// GameData Where a single game's data currently lives.
+type GameData struct {
+	AppID string
+	// The drive each kind of data is on, empty if the game has none
+	CompatDrive string
+	ShaderDrive string
+	Size        int64
+}
+
+// Get the folders a drive keeps every game's compatdata and shadercache in.
+func getDataRoots(drive string) (compat string, shader string) {
+	if drive == SteamDataRoot {
+		return SteamCompatRoot, SteamShaderRoot
+	}
+	return filepath.Join(drive, ExternalCompatRoot), filepath.Join(drive, ExternalShaderRoot)
+}
+
+// ResolveDrive Find the attached drive called name, which can be "ssd", a mount path or a drive label.
+func ResolveDrive(name string) (string, error) {
+	drives, err := getListOfAttachedDrives()
+	if err != nil {
+		return "", err
+	}
+	if strings.EqualFold(name, "ssd") || name == SteamDataRoot {
+		return SteamDataRoot, nil
+	}
+	var external []string
+	for _, drive := range drives {
+		if drive == SteamDataRoot {
+			continue
+		}
+		if drive == name || filepath.Base(drive) == name {
+			return drive, nil
+		}
+		external = append(external, drive)
+	}
+	return "", fmt.Errorf("%w: no attached drive called %s, expected ssd or one of %v", ErrInvalidArgument,
+		name, external)
+}
+
+// Find the drive holding one kind of a game's data, following the link on the SSD if there is one.
+func locateDataDrive(appID string, steamRoot string, externalRoot string, drives []string) string {
+	steamDir := filepath.Join(steamRoot, appID)
+	if isSymbolicLink(steamDir) {
+		target, err := os.Readlink(steamDir)
+		if err == nil && filepath.IsAbs(target) && doesFileExist(target) {
+			for _, drive := range drives {
+				if drive != SteamDataRoot && target == filepath.Join(drive, externalRoot, appID) {
+					return drive
+				}
+			}
+		}
+	} else if doesFileExist(steamDir) {
+		return SteamDataRoot
+	}
+	// A game that was never linked can still have data left on a card
+	candidate := findLinkCandidate(appID, externalRoot, drives)
+	if candidate != "" {
+		return filepath.Dir(filepath.Dir(filepath.Dir(candidate)))
+	}
+	return ""
+}
+
+// LocateGameData Find where the data of every game with compatdata or shadercache lives, and how big it is.
+func LocateGameData(ctx context.Context) ([]GameData, error) {
+	drives, err := getListOfAttachedDrives()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every game shows up on the SSD or on one of the cards
+	seen := make(map[string]bool)
+	var appIDs []string
+	for _, drive := range drives {
+		compat, shader := getDataRoots(drive)
+		for _, root := range []string{compat, shader} {
+			entries, _ := os.ReadDir(root)
+			for _, entry := range entries {
+				if !seen[entry.Name()] && isGameDataDirectory(entry.Name()) {
+					seen[entry.Name()] = true
+					appIDs = append(appIDs, entry.Name())
+				}
+			}
+		}
+	}
+
+	var games []GameData
+	for _, appID := range appIDs {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		game := GameData{
+			AppID:       appID,
+			CompatDrive: locateDataDrive(appID, SteamCompatRoot, ExternalCompatRoot, drives),
+			ShaderDrive: locateDataDrive(appID, SteamShaderRoot, ExternalShaderRoot, drives),
+		}
+		if game.CompatDrive == "" && game.ShaderDrive == "" {
+			continue
+		}
+		games = append(games, game)
+	}
+
+	// Only shown to help pick games, so sizes from the last time they were measured will do
+	var paths []string
+	for _, game := range games {
+		paths = append(paths, game.paths(DataTypeBoth)...)
+	}
+	sizes := estimateDirectorySizes(ctx, paths)
+	for i := range games {
+		for _, path := range games[i].paths(DataTypeBoth) {
+			games[i].Size += sizes[path]
+		}
+	}
+	return games, nil
+}
+
+// Get the paths of the kinds of data a game has, as selected by dataType.
+func (g GameData) paths(dataType string) []string {
+	var paths []string
+	if g.CompatDrive != "" && dataType != DataTypeShader {
+		compat, _ := getDataRoots(g.CompatDrive)
+		paths = append(paths, filepath.Join(compat, g.AppID))
+	}
+	if g.ShaderDrive != "" && dataType != DataTypeCompat {
+		_, shader := getDataRoots(g.ShaderDrive)
+		paths = append(paths, filepath.Join(shader, g.AppID))
+	}
+	return paths
+}
+
+// Location Describe where the game's data lives, for listing to the user.
+func (g GameData) Location() string {
+	name := getDriveName
+	switch {
+	case g.CompatDrive == g.ShaderDrive:
+		return name(g.CompatDrive)
+	case g.ShaderDrive == "":
+		return name(g.CompatDrive) + " (compatdata only)"
+	case g.CompatDrive == "":
+		return name(g.ShaderDrive) + " (shadercache only)"
+	}
+	return name(g.CompatDrive) + " (compatdata), " + name(g.ShaderDrive) + " (shadercache)"
+}
+
+// Work out which kinds of a game's data need moving to reach a drive, nil for those already there or missing.
+func (g GameData) planMove(to string, dataType string) (compat *dataMove, shader *dataMove) {
+	toCompat, toShader := getDataRoots(to)
+	if g.CompatDrive != "" && g.CompatDrive != to && dataType != DataTypeShader {
+		fromCompat, _ := getDataRoots(g.CompatDrive)
+		compat = &dataMove{fromRoot: fromCompat, toRoot: toCompat}
+	}
+	if g.ShaderDrive != "" && g.ShaderDrive != to && dataType != DataTypeCompat {
+		_, fromShader := getDataRoots(g.ShaderDrive)
+		shader = &dataMove{fromRoot: fromShader, toRoot: toShader}
+	}
+	return compat, shader
+}
+
+// MoveGames Move the selected kinds of data for exactly the given games to a drive, reporting copy progress to
+// onProgress if it isn't nil. Data that's already on the drive is left alone.
+func MoveGames(ctx context.Context, appIDs []string, to string, dataType string,
+	onProgress func(CopyProgress)) error {
+	if !Contains(DataTypes, dataType) {
+		return fmt.Errorf("%w: data type must be one of %v", ErrInvalidArgument, DataTypes)
+	}
+	recordOperationDetail("to", to)
+	recordOperationDetail("games", strings.Join(appIDs, ","))
+
+	games, err := LocateGameData(ctx)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]GameData, len(games))
+	for _, game := range games {
+		byID[game.AppID] = game
+	}
+
+	type plannedMove struct {
+		appID          string
+		compat, shader *dataMove
+	}
+	var plan []plannedMove
+	for _, appID := range appIDs {
+		game, ok := byID[appID]
+		if !ok {
+			return fmt.Errorf("%w: no compatdata or shadercache found for %s", ErrInvalidArgument, appID)
+		}
+		compat, shader := game.planMove(to, dataType)
+		if compat == nil && shader == nil {
+			CryoUtils.InfoLog.Println("Data for", appID, "is already on", to)
+			continue
+		}
+		plan = append(plan, plannedMove{appID: appID, compat: compat, shader: shader})
+	}
+
+	// Refuse up front rather than filling the drive partway through
+	var paths []string
+	for _, move := range plan {
+		for _, data := range []*dataMove{move.compat, move.shader} {
+			if data != nil {
+				paths = append(paths, filepath.Join(data.fromRoot, move.appID))
+			}
+		}
+	}
+	measured, err := getDirectorySizes(ctx, paths)
+	if err != nil {
+		return err
+	}
+	var sizes []gameSize
+	for _, move := range plan {
+		var size int64
+		for _, data := range []*dataMove{move.compat, move.shader} {
+			if data != nil {
+				size += measured[filepath.Join(data.fromRoot, move.appID)]
+			}
+		}
+		sizes = append(sizes, gameSize{appID: move.appID, size: size})
+	}
+	space, err := planDriveSpace(to, sizes)
+	if err != nil {
+		return err
+	}
+	err = checkDriveSpace(space)
+	if err != nil {
+		return err
+	}
+	CryoUtils.InfoLog.Println(space)
+
+	// Make sure the folders exist on a card that hasn't been used before
+	toCompat, toShader := getDataRoots(to)
+	_ = os.MkdirAll(toCompat, 0777)
+	_ = os.MkdirAll(toShader, 0777)
+
+	tracker := newCopyTracker(onProgress)
+	for _, move := range plan {
+		for _, data := range []*dataMove{move.compat, move.shader} {
+			if data == nil {
+				continue
+			}
+			err = tracker.addTree(ctx, filepath.Join(data.fromRoot, move.appID))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, move := range plan {
+		CryoUtils.InfoLog.Println("Moving", move.appID, "to", to+"...")
+		err = moveGameDirectory(ctx, move.appID, move.compat, move.shader, tracker)
+		if err != nil {
+			return err
+		}
+	}
+	CryoUtils.InfoLog.Println("Move finished:", tracker.snapshot())
+	return nil
+}
@@ -0,0 +1,230 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PlacementMove One game whose data isn't on the drive its library is on.
+type PlacementMove struct {
+	AppID string
+	// The drive each kind of data moves from, empty for a kind that's already in place or missing
+	CompatFrom string
+	ShaderFrom string
+	To         string
+	Size       int64
+}
+
+// PlacementPlan Every move needed to put each installed game's data on the drive it's installed on.
+type PlacementPlan struct {
+	Moves []PlacementMove
+	// The space on every drive involved, with the games left out of the plan because they don't fit
+	Spaces []DriveSpace
+}
+
+// Describe where the game's data moves from and to, for listing to the user.
+func (m PlacementMove) String() string {
+	var from []string
+	if m.CompatFrom != "" {
+		from = append(from, "compatdata from "+getDriveName(m.CompatFrom))
+	}
+	if m.ShaderFrom != "" {
+		from = append(from, "shadercache from "+getDriveName(m.ShaderFrom))
+	}
+	return strings.Join(from, ", ") + " to " + getDriveName(m.To)
+}
+
+// Get the short name of a drive, for listing to the user.
+func getDriveName(drive string) string {
+	if drive == SteamDataRoot {
+		return "SSD"
+	}
+	return filepath.Base(drive)
+}
+
+// Find the attached drive a library is on, empty if it isn't attached.
+func getLibraryDrive(library string, drives []string) string {
+	var found string
+	for _, drive := range drives {
+		if (library == drive || strings.HasPrefix(library, drive+string(filepath.Separator))) &&
+			len(drive) > len(found) {
+			found = drive
+		}
+	}
+	return found
+}
+
+// PlanPlacement Work out where every installed game's data belongs across every attached drive, and what has to
+// move to get it there. Games that don't fit on their drive are left out of the plan.
+func PlanPlacement(ctx context.Context) (*PlacementPlan, error) {
+	drives, err := getListOfAttachedDrives()
+	if err != nil {
+		return nil, err
+	}
+	libraries, err := findDataFolders()
+	if err != nil {
+		return nil, err
+	}
+	games, err := LocateGameData(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each installed game belongs on the drive its library is on
+	targets := make(map[string]string)
+	for _, library := range libraries {
+		drive := getLibraryDrive(library.Path, drives)
+		if drive == "" {
+			CryoUtils.InfoLog.Println("Library isn't attached, skipping:", library.Path)
+			continue
+		}
+		for _, game := range library.InstalledGames {
+			targets[strconv.Itoa(game)] = drive
+		}
+	}
+
+	moves := make(map[string]PlacementMove)
+	sources := make(map[string][]string)
+	var paths []string
+	for _, game := range games {
+		to, ok := targets[game.AppID]
+		if !ok {
+			continue
+		}
+		move := PlacementMove{AppID: game.AppID, To: to}
+		compat, shader := game.planMove(to, DataTypeBoth)
+		if compat != nil {
+			move.CompatFrom = game.CompatDrive
+			sources[game.AppID] = append(sources[game.AppID], filepath.Join(compat.fromRoot, game.AppID))
+		}
+		if shader != nil {
+			move.ShaderFrom = game.ShaderDrive
+			sources[game.AppID] = append(sources[game.AppID], filepath.Join(shader.fromRoot, game.AppID))
+		}
+		if compat == nil && shader == nil {
+			continue
+		}
+		moves[game.AppID] = move
+		paths = append(paths, sources[game.AppID]...)
+	}
+
+	measured, err := getDirectorySizes(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	incoming := make(map[string][]gameSize)
+	for _, game := range games {
+		move, ok := moves[game.AppID]
+		if !ok {
+			continue
+		}
+		for _, path := range sources[game.AppID] {
+			move.Size += measured[path]
+		}
+		moves[game.AppID] = move
+		incoming[move.To] = append(incoming[move.To], gameSize{appID: game.AppID, size: move.Size})
+	}
+
+	plan := new(PlacementPlan)
+	spaces := make(map[string]*DriveSpace)
+	for _, drive := range drives {
+		space, err := planDriveSpace(drive, incoming[drive])
+		if err != nil {
+			return nil, err
+		}
+		for _, appID := range space.Trimmed {
+			delete(moves, appID)
+		}
+		spaces[drive] = &space
+	}
+
+	for _, move := range moves {
+		plan.Moves = append(plan.Moves, move)
+		// Space only comes back on the drive the bulk of the data leaves, close enough for a summary
+		from := move.CompatFrom
+		if from == "" {
+			from = move.ShaderFrom
+		}
+		spaces[from].Outgoing += move.Size
+	}
+	sort.Slice(plan.Moves, func(i, j int) bool {
+		return plan.Moves[i].AppID < plan.Moves[j].AppID
+	})
+	for _, drive := range drives {
+		space := spaces[drive]
+		if space.Incoming != 0 || space.Outgoing != 0 || len(space.Trimmed) != 0 {
+			plan.Spaces = append(plan.Spaces, *space)
+		}
+	}
+	return plan, nil
+}
+
+// ApplyPlacement Carry out a placement plan, reporting copy progress to onProgress if it isn't nil.
+// Cancelling stops before the next game, or discards the copy of the game currently being moved.
+func ApplyPlacement(ctx context.Context, plan *PlacementPlan, onProgress func(CopyProgress)) error {
+	recordOperationDetail("placement", strconv.Itoa(len(plan.Moves))+" games")
+
+	tracker := newCopyTracker(onProgress)
+	for _, move := range plan.Moves {
+		compat, shader := move.dataMoves()
+		for _, data := range []*dataMove{compat, shader} {
+			if data == nil {
+				continue
+			}
+			err := tracker.addTree(ctx, filepath.Join(data.fromRoot, move.AppID))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	CryoUtils.InfoLog.Println("Moving", GetHumanByteSize(tracker.totalBytes.Load()), "in",
+		tracker.totalFiles.Load(), "files")
+
+	for _, move := range plan.Moves {
+		CryoUtils.InfoLog.Println("Moving", move.AppID+":", move)
+		toCompat, toShader := getDataRoots(move.To)
+		_ = os.MkdirAll(toCompat, 0777)
+		_ = os.MkdirAll(toShader, 0777)
+		compat, shader := move.dataMoves()
+		err := moveGameDirectory(ctx, move.AppID, compat, shader, tracker)
+		if err != nil {
+			return err
+		}
+	}
+	CryoUtils.InfoLog.Println("Move finished:", tracker.snapshot())
+	return nil
+}
+
+// Get the moves for each kind of the game's data, nil for a kind that stays where it is.
+func (m PlacementMove) dataMoves() (compat *dataMove, shader *dataMove) {
+	toCompat, toShader := getDataRoots(m.To)
+	if m.CompatFrom != "" {
+		fromCompat, _ := getDataRoots(m.CompatFrom)
+		compat = &dataMove{fromRoot: fromCompat, toRoot: toCompat}
+	}
+	if m.ShaderFrom != "" {
+		_, fromShader := getDataRoots(m.ShaderFrom)
+		shader = &dataMove{fromRoot: fromShader, toRoot: toShader}
+	}
+	return compat, shader
+}
@@ -0,0 +1,26 @@
+package internal
+
+import "testing"
+
+func TestGetLibraryDrive(t *testing.T) {
+	drives := []string{SteamDataRoot, "/run/media/mmcblk0p1", "/run/media/deck/usb", "/run/media/deck/usb2"}
+
+	tests := []struct {
+		name    string
+		library string
+		want    string
+	}{
+		{name: "SSD", library: SteamDataRoot, want: SteamDataRoot},
+		{name: "Card root", library: "/run/media/mmcblk0p1", want: "/run/media/mmcblk0p1"},
+		{name: "Folder on a drive", library: "/run/media/deck/usb2/Games", want: "/run/media/deck/usb2"},
+		{name: "Not attached", library: "/run/media/deck/gone", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getLibraryDrive(tt.library, drives); got != tt.want {
+				t.Errorf("getLibraryDrive() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The file kept next to each piece of quarantined data, describing where it came from.
+const quarantineMetadataName = "quarantine.json"
+
+// QuarantinedData Game data set aside by a cleanup, kept until it's restored, it expires, or the quarantine is
+// emptied.
+type QuarantinedData struct {
+	AppID string `json:"appid"`
+	// Where the data was, and is put back to on restore
+	Source        string    `json:"source"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+	Size          int64     `json:"size"`
+	// The folder in the quarantine holding the data and its metadata
+	Path string `json:"path"`
+}
+
+// Expired Check whether the data has been kept longer than days, never if days is 0.
+func (q QuarantinedData) Expired(days int, now time.Time) bool {
+	return days > 0 && now.Sub(q.QuarantinedAt) >= time.Duration(days)*24*time.Hour
+}
+
+func (q QuarantinedData) String() string {
+	return fmt.Sprintf("%s - %s - %s - %s", q.AppID, q.Source, q.QuarantinedAt.Format(time.DateTime),
+		GetHumanByteSize(q.Size))
+}
+
+// Get the quarantine for data in a compatdata or shadercache folder, next to it so data only has to be renamed.
+func getQuarantineDirectory(location string) string {
+	return filepath.Join(filepath.Dir(location), QuarantineDirectoryName)
+}
+
+// Get the quarantine of every attached drive.
+func getQuarantineDirectories() ([]string, error) {
+	locations, err := getListOfDataAllDataLocations()
+	if err != nil {
+		return nil, err
+	}
+	var directories []string
+	for _, location := range locations {
+		directory := getQuarantineDirectory(location)
+		if !Contains(directories, directory) {
+			directories = append(directories, directory)
+		}
+	}
+	return directories, nil
+}
+
+// Move game data into its drive's quarantine instead of deleting it. Data that doesn't exist is skipped.
+func quarantineData(ctx context.Context, path string) error {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return nil
+	}
+	size, err := measureDirectory(ctx, path)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
+	data := QuarantinedData{
+		AppID:         filepath.Base(path),
+		Source:        path,
+		QuarantinedAt: time.Now(),
+		Size:          size,
+	}
+	// Named so the same game's compatdata and shadercache, or a later cleanup of it, never collide
+	data.Path = filepath.Join(getQuarantineDirectory(filepath.Dir(path)), fmt.Sprintf("%s-%s-%d", data.AppID,
+		filepath.Base(filepath.Dir(path)), data.QuarantinedAt.UnixNano()))
+	err = os.MkdirAll(data.Path, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %w", data.Path, err)
+	}
+
+	// The metadata goes first, so data is never in the quarantine without a record of where it came from
+	contents, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(data.Path, quarantineMetadataName), contents, 0644)
+	if err == nil {
+		err = os.Rename(path, filepath.Join(data.Path, "data"))
+	}
+	if err != nil {
+		_ = os.RemoveAll(data.Path)
+		return fmt.Errorf("error quarantining %s: %w", path, err)
+	}
+	CryoUtils.InfoLog.Println("Quarantined", path, "in", data.Path)
+	return nil
+}
+
+// Read the metadata of quarantined data.
+func readQuarantinedData(path string) (QuarantinedData, error) {
+	var data QuarantinedData
+	contents, err := os.ReadFile(filepath.Join(path, quarantineMetadataName))
+	if err != nil {
+		return data, err
+	}
+	err = json.Unmarshal(contents, &data)
+	if err != nil {
+		return data, fmt.Errorf("error reading %s: %w", filepath.Join(path, quarantineMetadataName), err)
+	}
+	// Trust where the data is now over where it was recorded, in case the drive is mounted somewhere else
+	data.Path = path
+	return data, nil
+}
+
+// ListQuarantine Get everything in the quarantine of every attached drive, oldest first.
+func ListQuarantine() ([]QuarantinedData, error) {
+	directories, err := getQuarantineDirectories()
+	if err != nil {
+		return nil, err
+	}
+	var quarantined []QuarantinedData
+	for _, directory := range directories {
+		entries, _ := os.ReadDir(directory)
+		for _, entry := range entries {
+			data, err := readQuarantinedData(filepath.Join(directory, entry.Name()))
+			if err != nil {
+				CryoUtils.ErrorLog.Println("Skipping unreadable quarantine entry:", err)
+				continue
+			}
+			quarantined = append(quarantined, data)
+		}
+	}
+	sort.SliceStable(quarantined, func(i, j int) bool {
+		return quarantined[i].QuarantinedAt.Before(quarantined[j].QuarantinedAt)
+	})
+	return quarantined, nil
+}
+
+// RestoreQuarantine Put the quarantined data of the given games back where it was. If a game's data was cleaned up
+// more than once, the latest copy is restored. Returns the data that was restored.
+func RestoreQuarantine(ctx context.Context, appIDs []string) ([]QuarantinedData, error) {
+	for _, appID := range appIDs {
+		if _, err := strconv.Atoi(appID); err != nil {
+			return nil, fmt.Errorf("%w: %q is not an appid", ErrInvalidArgument, appID)
+		}
+	}
+	quarantined, err := ListQuarantine()
+	if err != nil {
+		return nil, err
+	}
+	// Oldest first, so the latest copy of each source wins
+	latest := make(map[string]QuarantinedData)
+	for _, data := range quarantined {
+		if Contains(appIDs, data.AppID) {
+			latest[data.Source] = data
+		}
+	}
+	if len(latest) == 0 {
+		return nil, fmt.Errorf("%w: nothing quarantined for %v", ErrInvalidArgument, appIDs)
+	}
+
+	var restored []QuarantinedData
+	var errs []error
+	for _, data := range latest {
+		if ctx.Err() != nil {
+			return restored, ctx.Err()
+		}
+		err := restoreQuarantinedData(data)
+		if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+			errs = append(errs, err)
+			continue
+		}
+		restored = append(restored, data)
+	}
+	sort.Slice(restored, func(i, j int) bool {
+		return restored[i].Source < restored[j].Source
+	})
+	return restored, errors.Join(errs...)
+}
+
+// Move quarantined data back to where it came from, refusing if Steam has made new data there since.
+func restoreQuarantinedData(data QuarantinedData) error {
+	if isGameRunning(data.AppID) {
+		return fmt.Errorf("skipped %s: %w", data.Source, ErrSteamRunning)
+	}
+	if _, err := os.Lstat(data.Source); err == nil {
+		return fmt.Errorf("unable to restore %s, it already exists, move or clean it up first", data.Source)
+	}
+	err := os.MkdirAll(filepath.Dir(data.Source), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(filepath.Join(data.Path, "data"), data.Source)
+	if err != nil {
+		return fmt.Errorf("error restoring %s: %w", data.Source, err)
+	}
+	CryoUtils.InfoLog.Println("Restored", data.Source, "from", data.Path)
+	return os.RemoveAll(data.Path)
+}
+
+// EmptyQuarantine Delete quarantined data for good, only what has been kept longer than the retention period if
+// expiredOnly is set. Returns how much space was freed.
+func EmptyQuarantine(ctx context.Context, expiredOnly bool) (int64, error) {
+	quarantined, err := ListQuarantine()
+	if err != nil {
+		return 0, err
+	}
+	days, now := GetQuarantineDays(), time.Now()
+	var freed int64
+	var errs []error
+	for _, data := range quarantined {
+		if ctx.Err() != nil {
+			return freed, ctx.Err()
+		}
+		if expiredOnly && !data.Expired(days, now) {
+			continue
+		}
+		err := os.RemoveAll(data.Path)
+		if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+			errs = append(errs, err)
+			continue
+		}
+		CryoUtils.InfoLog.Println("Deleted quarantined", data.Source, "from", data.Path)
+		freed += data.Size
+	}
+	return freed, errors.Join(errs...)
+}
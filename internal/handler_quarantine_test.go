@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuarantineData(t *testing.T) {
+	SizeCachePath = filepath.Join(t.TempDir(), "sizes.json")
+	directorySizes = sizeCache{}
+
+	root := t.TempDir()
+	compat := filepath.Join(root, "steamapps", "compatdata")
+	source := filepath.Join(compat, "620")
+	save := filepath.Join(source, "pfx", "save.dat")
+	if err := os.MkdirAll(filepath.Dir(save), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(save, []byte("progress"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := quarantineData(context.Background(), source); err != nil {
+		t.Fatalf("quarantineData() error = %v", err)
+	}
+	if _, err := os.Lstat(source); !os.IsNotExist(err) {
+		t.Fatalf("quarantineData() left %s in place", source)
+	}
+	// Missing data is skipped rather than failing the cleanup
+	if err := quarantineData(context.Background(), filepath.Join(compat, "400")); err != nil {
+		t.Fatalf("quarantineData() of missing data error = %v", err)
+	}
+
+	quarantine := getQuarantineDirectory(compat)
+	entries, err := os.ReadDir(quarantine)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("quarantine holds %v, %v, want one entry", entries, err)
+	}
+	data, err := readQuarantinedData(filepath.Join(quarantine, entries[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.AppID != "620" || data.Source != source || data.Size == 0 {
+		t.Errorf("readQuarantinedData() = %+v", data)
+	}
+
+	// Steam recreating the prefix in the meantime blocks a restore
+	if err := os.MkdirAll(source, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreQuarantinedData(data); err == nil {
+		t.Errorf("restoreQuarantinedData() over existing data succeeded")
+	}
+	_ = os.Remove(source)
+	if err := restoreQuarantinedData(data); err != nil {
+		t.Fatalf("restoreQuarantinedData() error = %v", err)
+	}
+	if contents, err := os.ReadFile(save); err != nil || string(contents) != "progress" {
+		t.Errorf("restored save = %q, %v", contents, err)
+	}
+	if _, err := os.Stat(data.Path); !os.IsNotExist(err) {
+		t.Errorf("restoreQuarantinedData() left %s behind", data.Path)
+	}
+}
+
+func TestQuarantinedDataExpired(t *testing.T) {
+	now := time.Date(2023, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		age  time.Duration
+		days int
+		want bool
+	}{
+		{name: "Within retention", age: 6 * 24 * time.Hour, days: 7, want: false},
+		{name: "Past retention", age: 7 * 24 * time.Hour, days: 7, want: true},
+		{name: "Kept until emptied", age: 365 * 24 * time.Hour, days: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := QuarantinedData{QuarantinedAt: now.Add(-tt.age)}
+			if got := data.Expired(tt.days, now); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats a storage report can be exported in.
+const (
+	ReportFormatCSV      = "csv"
+	ReportFormatJSON     = "json"
+	ReportFormatMarkdown = "markdown"
+)
+
+// ReportFormats Every format a storage report can be exported in
+var ReportFormats = []string{ReportFormatMarkdown, ReportFormatCSV, ReportFormatJSON}
+
+// Orders a storage report can be sorted in.
+const (
+	ReportSortSize  = "size"
+	ReportSortAppID = "appid"
+	ReportSortName  = "name"
+)
+
+// ReportSorts Every order a storage report can be sorted in
+var ReportSorts = []string{ReportSortSize, ReportSortAppID, ReportSortName}
+
+// The columns of a storage report, in order.
+var reportColumns = []string{"AppID", "Name", "Installed", "Location", "Compatdata", "Shadercache", "Total",
+	"Compatdata Link", "Shadercache Link"}
+
+// GetStorageReport Get the space every game's data takes, sorted by sortBy, largest first when sorting by size. With
+// estimate set, sizes are from the last time each game's data was measured.
+func GetStorageReport(ctx context.Context, sortBy string, estimate bool) ([]GameInfo, error) {
+	if !Contains(ReportSorts, sortBy) {
+		return nil, fmt.Errorf("%w: sort must be one of %v", ErrInvalidArgument, ReportSorts)
+	}
+	games, err := listGames(ctx, estimate)
+	if err != nil {
+		return nil, err
+	}
+	sortStorageReport(games, sortBy)
+	return games, nil
+}
+
+// Sort the games of a storage report in place.
+func sortStorageReport(games []GameInfo, sortBy string) {
+	sort.SliceStable(games, func(i, j int) bool {
+		switch sortBy {
+		case ReportSortSize:
+			return games[i].TotalSize() > games[j].TotalSize()
+		case ReportSortName:
+			return strings.ToLower(games[i].Name) < strings.ToLower(games[j].Name)
+		}
+		return games[i].AppID < games[j].AppID
+	})
+}
+
+// Get a game's row in a storage report, with sizes in bytes if raw is set, or readable sizes otherwise.
+func getReportRow(game GameInfo, raw bool) []string {
+	size := GetHumanByteSize
+	if raw {
+		size = func(size int64) string {
+			return strconv.FormatInt(size, 10)
+		}
+	}
+	return []string{strconv.Itoa(game.AppID), game.Name, strconv.FormatBool(game.Installed), game.Location,
+		size(game.CompatSize), size(game.ShaderSize), size(game.TotalSize()), game.CompatLink, game.ShaderLink}
+}
+
+// WriteStorageReport Export a storage report in one of the ReportFormats.
+func WriteStorageReport(w io.Writer, games []GameInfo, format string) error {
+	switch format {
+	case ReportFormatCSV:
+		writer := csv.NewWriter(w)
+		_ = writer.Write(reportColumns)
+		for _, game := range games {
+			_ = writer.Write(getReportRow(game, true))
+		}
+		writer.Flush()
+		return writer.Error()
+	case ReportFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(games)
+	case ReportFormatMarkdown:
+		var total int64
+		lines := []string{
+			"| " + strings.Join(reportColumns, " | ") + " |",
+			"|" + strings.Repeat(" --- |", len(reportColumns)),
+		}
+		for _, game := range games {
+			row := getReportRow(game, false)
+			for i := range row {
+				// Keep names from breaking the table
+				row[i] = strings.ReplaceAll(row[i], "|", "\\|")
+			}
+			lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+			total += game.TotalSize()
+		}
+		lines = append(lines, "", fmt.Sprintf("%d games, %s in total", len(games), GetHumanByteSize(total)))
+		_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+		return err
+	}
+	return fmt.Errorf("%w: format must be one of %v", ErrInvalidArgument, ReportFormats)
+}
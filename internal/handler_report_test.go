@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteStorageReport(t *testing.T) {
+	games := []GameInfo{
+		{AppID: 620, Name: "Portal 2", Installed: true, Location: "SSD", CompatSize: 1024, ShaderSize: 2048,
+			CompatLink: LinkStatusDirectory, ShaderLink: LinkStatusDirectory},
+		{AppID: 400, Name: "Portal | GOTY", Location: "mmcblk0p1", CompatSize: 4096, CompatLink: LinkStatusValid},
+	}
+	sortStorageReport(games, ReportSortSize)
+	if games[0].AppID != 400 {
+		t.Errorf("sortStorageReport() put %d first, want the largest", games[0].AppID)
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{format: ReportFormatCSV, want: []string{
+			"AppID,Name,Installed,Location,Compatdata,Shadercache,Total,Compatdata Link,Shadercache Link",
+			"400,Portal | GOTY,false,mmcblk0p1,4096,0,4096,valid link,",
+			"620,Portal 2,true,SSD,1024,2048,3072,directory,directory",
+		}},
+		{format: ReportFormatMarkdown, want: []string{
+			"| 400 | Portal \\| GOTY | false | mmcblk0p1 | 4.0KB | 0B | 4.0KB | valid link |  |",
+			"2 games, 7.0KB in total",
+		}},
+		{format: ReportFormatJSON, want: []string{`"appid": 400`, `"compat_link": "valid link"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteStorageReport(&buf, games, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(buf.String(), line) {
+					t.Errorf("WriteStorageReport() = %q, want it to contain %q", buf.String(), line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Shortcut A non-Steam game added to Steam, like an emulator or another launcher.
+type Shortcut struct {
+	AppID uint32
+	Name  string
+	Exe   string
+}
+
+// Work out a shortcut's appid the way Steam does, which is also the name of its compatdata folder.
+func getShortcutAppID(exe string, name string) uint32 {
+	return crc32.ChecksumIEEE([]byte(exe+name)) | 0x80000000
+}
+
+// Read the shortcuts in a binary shortcuts.vdf.
+func readShortcuts(path string) ([]Shortcut, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	kv := &kvReader{r: bytes.NewReader(contents)}
+	root, err := kv.readMap()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	entries, _ := getVDFValue(root, "shortcuts").(map[string]interface{})
+	var shortcuts []Shortcut
+	for _, entry := range entries {
+		values, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		shortcut := Shortcut{}
+		shortcut.Name, _ = getVDFValue(values, "AppName").(string)
+		shortcut.Exe, _ = getVDFValue(values, "Exe").(string)
+		// Newer clients store the appid, older ones leave it to be worked out
+		if appID, ok := getVDFValue(values, "appid").(int32); ok && appID != 0 {
+			shortcut.AppID = uint32(appID)
+		} else {
+			shortcut.AppID = getShortcutAppID(shortcut.Exe, shortcut.Name)
+		}
+		shortcuts = append(shortcuts, shortcut)
+	}
+	return shortcuts, nil
+}
+
+// The shortcuts of every Steam user, read again whenever a shortcuts.vdf changes so shortcuts added while the GUI is
+// open are picked up.
+var shortcutCache = struct {
+	sync.Mutex
+	modTimes  map[string]time.Time
+	shortcuts map[int]Shortcut
+}{}
+
+// Get the shortcuts of every Steam user on the Deck, by appid.
+func getShortcuts() map[int]Shortcut {
+	shortcutCache.Lock()
+	defer shortcutCache.Unlock()
+
+	paths, _ := filepath.Glob(filepath.Join(UserDataDirectory, "*", "config", "shortcuts.vdf"))
+	modTimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	if shortcutCache.shortcuts != nil && sameModTimes(modTimes, shortcutCache.modTimes) {
+		return shortcutCache.shortcuts
+	}
+
+	shortcuts := make(map[int]Shortcut)
+	for _, path := range paths {
+		found, err := readShortcuts(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			CryoUtils.ErrorLog.Println(err)
+			continue
+		}
+		for _, shortcut := range found {
+			shortcuts[int(shortcut.AppID)] = shortcut
+		}
+	}
+	shortcutCache.modTimes, shortcutCache.shortcuts = modTimes, shortcuts
+	return shortcuts
+}
+
+// Whether two sets of files have the same modification times.
+func sameModTimes(a map[string]time.Time, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, modTime := range a {
+		if other, ok := b[path]; !ok || !other.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether an appid belongs to one of the non-Steam games added to Steam.
+func isShortcutApp(appID int) bool {
+	_, ok := getShortcuts()[appID]
+	return ok
+}
+
+// Whether CryoUtilities may move or clean an app's compatdata and shadercache. Anything above SteamGameMaxInteger
+// that isn't a known shortcut, and tools like Proton, are left alone.
+func isManagedApp(appID int) bool {
+	if appID == 0 || isToolApp(appID) {
+		return false
+	}
+	return appID <= SteamGameMaxInteger || isShortcutApp(appID)
+}
+
+// Whether a folder in a data root belongs to a game, rather than a Proton version or Steam itself.
+func isGameDataDirectory(name string) bool {
+	appID, err := strconv.Atoi(name)
+	return err == nil && strconv.Itoa(appID) == name && isManagedApp(appID)
+}
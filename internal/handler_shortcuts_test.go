@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReadShortcuts(t *testing.T) {
+	var buf bytes.Buffer
+	str := func(key string, value string) {
+		buf.WriteByte(kvTypeString)
+		buf.WriteString(key + "\x00" + value + "\x00")
+	}
+	buf.WriteByte(kvTypeMap)
+	buf.WriteString("shortcuts\x00")
+	// Written by a newer client, with the appid stored
+	buf.WriteByte(kvTypeMap)
+	buf.WriteString("0\x00")
+	buf.WriteByte(kvTypeInt32)
+	buf.WriteString("appid\x00")
+	_ = binary.Write(&buf, binary.LittleEndian, int32(-1294967296))
+	str("AppName", "Heroic")
+	str("Exe", "\"/usr/bin/heroic\"")
+	buf.WriteByte(kvTypeEnd)
+	// Written by an older client, the appid has to be worked out
+	buf.WriteByte(kvTypeMap)
+	buf.WriteString("1\x00")
+	str("appname", "EmulationStation-DE")
+	str("exe", "\"/usr/bin/flatpak\"")
+	buf.WriteByte(kvTypeEnd)
+	buf.Write([]byte{kvTypeEnd, kvTypeEnd})
+
+	path := filepath.Join(t.TempDir(), "shortcuts.vdf")
+	_ = os.WriteFile(path, buf.Bytes(), 0644)
+
+	got, err := readShortcuts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(got, func(i, j int) bool {
+		return got[i].Name < got[j].Name
+	})
+	want := []Shortcut{
+		{AppID: 2206641107, Name: "EmulationStation-DE", Exe: "\"/usr/bin/flatpak\""},
+		{AppID: 3000000000, Name: "Heroic", Exe: "\"/usr/bin/heroic\""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("readShortcuts() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("readShortcuts()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGetShortcutsRefreshes(t *testing.T) {
+	oldUserData := UserDataDirectory
+	UserDataDirectory = t.TempDir()
+	defer func() { UserDataDirectory = oldUserData }()
+
+	path := filepath.Join(UserDataDirectory, "1000", "config", "shortcuts.vdf")
+	_ = os.MkdirAll(filepath.Dir(path), 0755)
+	write := func(modTime time.Time, names ...string) {
+		var buf bytes.Buffer
+		buf.WriteByte(kvTypeMap)
+		buf.WriteString("shortcuts\x00")
+		for i, name := range names {
+			buf.WriteByte(kvTypeMap)
+			buf.WriteString(strconv.Itoa(i) + "\x00")
+			buf.WriteByte(kvTypeString)
+			buf.WriteString("AppName\x00" + name + "\x00")
+			buf.WriteByte(kvTypeString)
+			buf.WriteString("Exe\x00\"/usr/bin/" + name + "\"\x00")
+			buf.WriteByte(kvTypeEnd)
+		}
+		buf.Write([]byte{kvTypeEnd, kvTypeEnd})
+		_ = os.WriteFile(path, buf.Bytes(), 0644)
+		_ = os.Chtimes(path, modTime, modTime)
+	}
+	heroic := int(getShortcutAppID("\"/usr/bin/heroic\"", "heroic"))
+	emudeck := int(getShortcutAppID("\"/usr/bin/emudeck\"", "emudeck"))
+
+	write(time.Now().Add(-time.Hour), "heroic")
+	if !isShortcutApp(heroic) || isShortcutApp(emudeck) {
+		t.Fatalf("isShortcutApp() doesn't match the first shortcuts.vdf")
+	}
+	// Added while the GUI was open
+	write(time.Now(), "heroic", "emudeck")
+	if !isShortcutApp(heroic) || !isShortcutApp(emudeck) {
+		t.Errorf("isShortcutApp() didn't pick up the shortcut added to shortcuts.vdf")
+	}
+}
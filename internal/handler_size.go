@@ -0,0 +1,283 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Directories being read by every measurement at once, shared so sizing many trees doesn't oversubscribe the drive.
+var sizeWorkers = make(chan struct{}, SizeWorkers)
+
+// The measured size of a directory tree, valid as long as none of its directories have changed.
+type sizeCacheEntry struct {
+	Size int64 `json:"size"`
+	// Hash of the path and mtime of every directory in the tree, adding, removing or renaming anything changes it
+	Fingerprint uint64 `json:"fingerprint"`
+}
+
+// Every measured tree, keyed by path, loaded from SizeCachePath the first time it's needed.
+type sizeCache struct {
+	mu      sync.Mutex
+	entries map[string]sizeCacheEntry
+	dirty   bool
+}
+
+var directorySizes sizeCache
+
+// Get the cached measurement of a tree, if there is one.
+func (c *sizeCache) get(path string) (sizeCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	entry, ok := c.entries[path]
+	return entry, ok
+}
+
+func (c *sizeCache) set(path string, entry sizeCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	if c.entries[path] != entry {
+		c.entries[path] = entry
+		c.dirty = true
+	}
+}
+
+// Read the cache from disk, starting empty if it's missing or unreadable. Must be called with mu held.
+func (c *sizeCache) load() {
+	if c.entries != nil {
+		return
+	}
+	c.entries = make(map[string]sizeCacheEntry)
+	contents, err := os.ReadFile(SizeCachePath)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(contents, &c.entries)
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Ignoring unreadable size cache:", err)
+		c.entries = make(map[string]sizeCacheEntry)
+	}
+}
+
+// Write the cache to disk if anything changed, readable by both the GUI user and root. Trees that are gone are dropped,
+// but not those on a card that isn't attached right now.
+func (c *sizeCache) save() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return
+	}
+	for path := range c.entries {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Dir(path)); err == nil {
+			delete(c.entries, path)
+		}
+	}
+	contents, err := json.Marshal(c.entries)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(SizeCachePath), 0777)
+	err = os.WriteFile(SizeCachePath, contents, 0666)
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to save size cache:", err)
+		return
+	}
+	c.dirty = false
+}
+
+// Get the space a file takes on disk, which for sparse or compressed files can be far less than its length.
+func getAllocatedSize(info os.FileInfo) int64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return stat.Blocks * 512
+	}
+	return info.Size()
+}
+
+// A walk of one directory tree, spreading the directories over the shared sizeWorkers.
+type treeWalk struct {
+	ctx         context.Context
+	withFiles   bool
+	size        atomic.Int64
+	fingerprint atomic.Uint64
+	wg          sync.WaitGroup
+	errOnce     sync.Once
+	err         error
+}
+
+func (w *treeWalk) fail(err error) {
+	// Anything deleted while the walk is running simply isn't counted
+	if os.IsNotExist(err) {
+		return
+	}
+	w.errOnce.Do(func() {
+		w.err = err
+	})
+}
+
+// Read one directory, adding up its files and handing its subdirectories to a free worker, or reading them here if
+// there isn't one.
+func (w *treeWalk) walk(dir string, info os.FileInfo) {
+	defer w.wg.Done()
+	// Summing the hashes keeps the fingerprint independent of the order directories are read in
+	w.fingerprint.Add(xxhash.Sum64String(dir + "\x00" + strconv.FormatInt(info.ModTime().UnixNano(), 10)))
+	w.size.Add(getAllocatedSize(info))
+	if w.ctx.Err() != nil {
+		w.fail(w.ctx.Err())
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.fail(err)
+		return
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && !w.withFiles {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			w.fail(err)
+			continue
+		}
+		if !entry.IsDir() {
+			w.size.Add(getAllocatedSize(info))
+			continue
+		}
+		w.wg.Add(1)
+		select {
+		case sizeWorkers <- struct{}{}:
+			go func() {
+				defer RecoverAndReport()
+				defer func() { <-sizeWorkers }()
+				w.walk(path, info)
+			}()
+		default:
+			w.walk(path, info)
+		}
+	}
+}
+
+// Walk a directory tree, returning the space it takes and its fingerprint. Files are only looked at if withFiles is
+// set, otherwise the size only covers the directories themselves.
+func walkTree(ctx context.Context, root string, withFiles bool) (int64, uint64, error) {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !info.IsDir() {
+		return getAllocatedSize(info), 0, nil
+	}
+	w := &treeWalk{ctx: ctx, withFiles: withFiles}
+	w.wg.Add(1)
+	w.walk(root, info)
+	w.wg.Wait()
+	return w.size.Load(), w.fingerprint.Load(), w.err
+}
+
+// Measure the space a directory tree takes on disk. A cached size is used if none of the tree's directories have
+// changed since it was measured, which only needs the directories read rather than every file. Missing trees are
+// empty.
+func measureDirectory(ctx context.Context, path string) (int64, error) {
+	path = filepath.Clean(path)
+	if cached, ok := directorySizes.get(path); ok {
+		_, fingerprint, err := walkTree(ctx, path, false)
+		if err == nil && fingerprint == cached.Fingerprint {
+			return cached.Size, nil
+		}
+	}
+	size, fingerprint, err := walkTree(ctx, path, true)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return size, fmt.Errorf("error measuring %s: %w", path, err)
+	}
+	directorySizes.set(path, sizeCacheEntry{Size: size, Fingerprint: fingerprint})
+	return size, nil
+}
+
+// Measure several directory trees at once, keyed by path. On error, the sizes of the trees that couldn't be fully
+// read are what could be counted.
+func getDirectorySizes(ctx context.Context, paths []string) (map[string]int64, error) {
+	sizes := make(map[string]int64)
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, path := range paths {
+		sizeWorkers <- struct{}{}
+		wg.Add(1)
+		go func(path string) {
+			defer RecoverAndReport()
+			defer wg.Done()
+			defer func() { <-sizeWorkers }()
+			size, err := measureDirectory(ctx, path)
+			mu.Lock()
+			defer mu.Unlock()
+			sizes[path] = size
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}(path)
+	}
+	wg.Wait()
+	directorySizes.save()
+	if ctx.Err() != nil {
+		return sizes, ctx.Err()
+	}
+	return sizes, errors.Join(errs...)
+}
+
+// Get the size each tree had when it was last measured, without checking whether it has changed since. Trees that
+// were never measured are measured now. Callers showing sizes can use these first, then get the exact sizes with
+// getDirectorySizes.
+func estimateDirectorySizes(ctx context.Context, paths []string) map[string]int64 {
+	sizes := make(map[string]int64)
+	var unknown []string
+	for _, path := range paths {
+		if cached, ok := directorySizes.get(filepath.Clean(path)); ok {
+			sizes[path] = cached.Size
+		} else {
+			unknown = append(unknown, path)
+		}
+	}
+	measured, err := getDirectorySizes(ctx, unknown)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
+	for path, size := range measured {
+		sizes[path] = size
+	}
+	return sizes
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMeasureDirectory(t *testing.T) {
+	SizeCachePath = filepath.Join(t.TempDir(), "sizes.json")
+	directorySizes = sizeCache{}
+
+	root := t.TempDir()
+	write := func(name string, size int) {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pfx/drive_c/a.bin", 64*1024)
+	write("pfx/drive_c/windows/b.bin", 128*1024)
+	// A sparse file only takes the blocks that were written
+	sparse, err := os.Create(filepath.Join(root, "sparse.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sparse.Truncate(64 * 1024 * 1024); err != nil {
+		t.Fatal(err)
+	}
+	_ = sparse.Close()
+
+	first, err := measureDirectory(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first < 192*1024 || first >= 64*1024*1024 {
+		t.Fatalf("measureDirectory() = %d, want allocated size of the files", first)
+	}
+	if _, ok := directorySizes.get(root); !ok {
+		t.Fatalf("measureDirectory() didn't cache %s", root)
+	}
+
+	tests := []struct {
+		name   string
+		change func()
+		grow   bool
+	}{
+		{name: "Unchanged tree", change: func() {}},
+		{name: "File added", change: func() { write("pfx/drive_c/windows/c.bin", 256*1024) }, grow: true},
+		{name: "Directory added", change: func() { write("pfx/new/d.bin", 256*1024) }, grow: true},
+	}
+
+	previous := first
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			got, err := measureDirectory(context.Background(), root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.grow && got <= previous {
+				t.Errorf("measureDirectory() = %d, want more than %d", got, previous)
+			}
+			if !tt.grow && got != previous {
+				t.Errorf("measureDirectory() = %d, want %d", got, previous)
+			}
+			previous = got
+		})
+	}
+
+	sizes, err := getDirectorySizes(context.Background(), []string{root, filepath.Join(root, "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes[root] != previous || sizes[filepath.Join(root, "missing")] != 0 {
+		t.Errorf("getDirectorySizes() = %v", sizes)
+	}
+	if _, err := os.Stat(SizeCachePath); err != nil {
+		t.Errorf("getDirectorySizes() didn't save the cache: %v", err)
+	}
+}
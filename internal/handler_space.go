@@ -0,0 +1,135 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// DriveSpace How a move changes the space on one drive, and the games left out so it fits.
+type DriveSpace struct {
+	Drive      string `json:"drive"`
+	Total      int64  `json:"total"`
+	FreeBefore int64  `json:"free_before"`
+	// Bytes of game data coming to and leaving the drive
+	Incoming int64 `json:"incoming"`
+	Outgoing int64 `json:"outgoing"`
+	// Games bound for the drive that don't fit in its free space, less SpaceOverhead
+	Trimmed []string `json:"trimmed"`
+}
+
+// The size of the data one game would bring to a drive.
+type gameSize struct {
+	appID string
+	size  int64
+}
+
+// FreeAfter The space the drive will have free once the move is done.
+func (s DriveSpace) FreeAfter() int64 {
+	return s.FreeBefore - s.Incoming + s.Outgoing
+}
+
+// String Describe the drive's usage before and after the move.
+func (s DriveSpace) String() string {
+	return fmt.Sprintf("%s: %s used, %s free before, %s used, %s free after (of %s)", getDriveName(s.Drive),
+		GetHumanByteSize(s.Total-s.FreeBefore), GetHumanByteSize(s.FreeBefore),
+		GetHumanByteSize(s.Total-s.FreeAfter()), GetHumanByteSize(s.FreeAfter()), GetHumanByteSize(s.Total))
+}
+
+// Get the total size and free space of the filesystem a drive is on.
+func getDriveSpace(drive string) (total int64, free int64, err error) {
+	var stat unix.Statfs_t
+	err = unix.Statfs(drive, &stat)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting free space for %s: %w", drive, err)
+	}
+	return int64(stat.Blocks * uint64(stat.Bsize)), int64(stat.Bfree * uint64(stat.Bsize)), nil
+}
+
+// Work out how the data bound for a drive fits in its free space, trimming games when it doesn't.
+func planDriveSpace(drive string, incoming []gameSize) (DriveSpace, error) {
+	total, free, err := getDriveSpace(drive)
+	if err != nil {
+		return DriveSpace{}, err
+	}
+	space := fitDriveSpace(DriveSpace{Drive: drive, Total: total, FreeBefore: free}, incoming)
+	if len(space.Trimmed) != 0 {
+		CryoUtils.InfoLog.Println("Not enough space on", drive, "for", space.Trimmed)
+	}
+	return space, nil
+}
+
+// Fit as many games as possible into the drive's free space less SpaceOverhead, smallest first, and trim the rest.
+func fitDriveSpace(space DriveSpace, incoming []gameSize) DriveSpace {
+	sorted := append([]gameSize{}, incoming...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].size < sorted[j].size
+	})
+
+	available := space.FreeBefore - int64(SpaceOverhead)
+	for _, game := range sorted {
+		if space.Incoming+game.size > available {
+			space.Trimmed = append(space.Trimmed, game.appID)
+			continue
+		}
+		space.Incoming += game.size
+	}
+	sort.Strings(space.Trimmed)
+	return space
+}
+
+// Refuse a move that doesn't fit, naming the games that would have to be left out.
+func checkDriveSpace(space DriveSpace) error {
+	if len(space.Trimmed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s needs %s kept free, leave out %s or free up space", ErrInsufficientSpace,
+		filepath.Base(space.Drive), GetHumanByteSize(int64(SpaceOverhead)), strings.Join(space.Trimmed, ", "))
+}
+
+// Fit the sync into the space each drive has, removing the games that don't fit from the queues.
+func (d *DataToMove) planSpace(ctx context.Context, left string, right string) ([]DriveSpace, error) {
+	err := d.getSpaceNeeded(ctx, left, right)
+	if err != nil {
+		return nil, err
+	}
+	// Data queued on the right is bound for the left, and the other way around
+	leftSpace, err := planDriveSpace(left, d.right.gameSizes())
+	if err != nil {
+		return nil, err
+	}
+	rightSpace, err := planDriveSpace(right, d.left.gameSizes())
+	if err != nil {
+		return nil, err
+	}
+	for _, game := range leftSpace.Trimmed {
+		d.right.remove(game)
+	}
+	for _, game := range rightSpace.Trimmed {
+		d.left.remove(game)
+	}
+
+	d.rightSize, d.leftSize = leftSpace.Incoming, rightSpace.Incoming
+	leftSpace.Outgoing, rightSpace.Outgoing = d.leftSize, d.rightSize
+	return []DriveSpace{leftSpace, rightSpace}, nil
+}
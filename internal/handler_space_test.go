@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFitDriveSpace(t *testing.T) {
+	gb := int64(GigabyteMultiplier)
+	incoming := []gameSize{{"100", 4 * gb}, {"200", 1 * gb}, {"300", 2 * gb}}
+
+	tests := []struct {
+		name         string
+		free         int64
+		wantIncoming int64
+		wantTrimmed  []string
+	}{
+		{name: "Everything fits", free: 10 * gb, wantIncoming: 7 * gb},
+		{name: "Exactly fits with overhead", free: 8 * gb, wantIncoming: 7 * gb},
+		{name: "Largest left out", free: 6 * gb, wantIncoming: 3 * gb, wantTrimmed: []string{"100"}},
+		{name: "Nothing fits", free: gb, wantTrimmed: []string{"100", "200", "300"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitDriveSpace(DriveSpace{FreeBefore: tt.free}, incoming)
+			if got.Incoming != tt.wantIncoming || !reflect.DeepEqual(got.Trimmed, tt.wantTrimmed) {
+				t.Errorf("fitDriveSpace() = %d, %v, want %d, %v", got.Incoming, got.Trimmed, tt.wantIncoming,
+					tt.wantTrimmed)
+			}
+		})
+	}
+}
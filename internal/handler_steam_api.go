@@ -17,64 +17,189 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
 	"time"
 )
 
-type AppResponse struct {
-	Applist struct {
-		Apps []struct {
-			Name  string `json:"name"`
-			Appid int    `json:"appid"`
-		} `json:"apps"`
-	} `json:"applist"`
+// The game names kept on disk between runs, games the API doesn't know are kept with an empty name.
+type appNameCache struct {
+	Updated time.Time      `json:"updated"`
+	Names   map[int]string `json:"names"`
 }
 
-// Query the Steam API to get a JSON response object.
-func querySteamAPI() (AppResponse, error) {
-	var steamResponse AppResponse
-	client := http.Client{
-		Timeout: time.Second * 30, // Timeout after 30 seconds
-	}
+// Whether the names were already looked up in the Steam API during this run, so it's only ever asked once.
+var appNamesRefreshed atomic.Bool
 
-	req, err := http.NewRequest(http.MethodGet, SteamApiUrl, nil)
+// Query the Steam API at url, keeping only the names of the wanted appids and giving up when the context is done.
+// The full app list is huge, so it's decoded as it streams in rather than read into memory first.
+func querySteamAPI(ctx context.Context, url string, wanted map[int]bool) (map[int]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return steamResponse, err
+		return nil, err
 	}
-
 	req.Header.Set("User-Agent", "cryoutilities")
 
-	res, err := client.Do(req)
+	res, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return steamResponse, err
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error querying the Steam API: %s", res.Status)
 	}
 
-	if res.Body != nil {
-		defer res.Body.Close()
+	// Skip ahead to the list of apps, {"applist": {"apps": [...]}}
+	decoder := json.NewDecoder(res.Body)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("error reading the Steam API response: %w", err)
+		}
+		if token == "apps" {
+			break
+		}
+	}
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return nil, fmt.Errorf("error reading the Steam API response: the app list isn't a list")
 	}
 
-	body, err := io.ReadAll(res.Body)
+	names := make(map[int]string)
+	for decoder.More() {
+		var app struct {
+			Name  string `json:"name"`
+			Appid int    `json:"appid"`
+		}
+		err = decoder.Decode(&app)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the Steam API response: %w", err)
+		}
+		if wanted[app.Appid] {
+			names[app.Appid] = app.Name
+		}
+	}
+	return names, nil
+}
+
+// Read the game names saved by the last lookup.
+func readAppNameCache() (*appNameCache, error) {
+	contents, err := os.ReadFile(AppNameCachePath)
+	if err != nil {
+		return nil, err
+	}
+	var cache appNameCache
+	err = json.Unmarshal(contents, &cache)
 	if err != nil {
-		return steamResponse, err
+		return nil, fmt.Errorf("error reading %s: %w", AppNameCachePath, err)
 	}
+	return &cache, nil
+}
 
-	err = json.Unmarshal(body, &steamResponse)
+// Save the game names, readable by both the GUI user and root.
+func writeAppNameCache(cache *appNameCache) error {
+	contents, err := json.Marshal(cache)
 	if err != nil {
-		return steamResponse, err
+		return err
 	}
+	_ = os.MkdirAll(InstallDirectory, 0777)
+	return os.WriteFile(AppNameCachePath, contents, 0666)
+}
 
-	return steamResponse, nil
+// Get the appid of every game with data or an install on an attached drive.
+func getLocalAppIDs() map[int]bool {
+	appIDs := make(map[int]bool)
+	drives, err := getListOfAttachedDrives()
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
+	for _, drive := range drives {
+		compat, shader := getDataRoots(drive)
+		for _, root := range []string{compat, shader} {
+			entries, _ := os.ReadDir(root)
+			for _, entry := range entries {
+				appID, err := strconv.Atoi(entry.Name())
+				if err == nil {
+					appIDs[appID] = true
+				}
+			}
+		}
+	}
+	libraries, err := findDataFolders()
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
+	for _, library := range libraries {
+		for _, appID := range library.InstalledGames {
+			appIDs[appID] = true
+		}
+	}
+	return appIDs
+}
+
+// RefreshAppNames Look the names of every local game up in the Steam API, and save them for later runs.
+func RefreshAppNames(ctx context.Context) (map[int]string, error) {
+	appNamesRefreshed.Store(true)
+	ctx, cancel := context.WithTimeout(ctx, SteamAPITimeout)
+	defer cancel()
+
+	wanted := getLocalAppIDs()
+	names, err := querySteamAPI(ctx, SteamApiUrl, wanted)
+	if err != nil {
+		return nil, err
+	}
+	// Remember the games the API doesn't know too, so they aren't looked up again until the cache expires
+	for appID := range wanted {
+		if _, ok := names[appID]; !ok {
+			names[appID] = ""
+		}
+	}
+	CryoUtils.InfoLog.Println("Found", len(names), "game names in the Steam API")
+	CryoUtils.SteamAPIResponse = names
+	return names, writeAppNameCache(&appNameCache{Updated: time.Now(), Names: names})
 }
 
-// Parse a SteamAPI response object and create a map of all games.
-func generateGameMap(response AppResponse) map[int]string {
-	gameMap := map[int]string{}
+// Load the game names once, from the cache while it's fresh and from the Steam API otherwise.
+func loadSteamAPIResponse(ctx context.Context) {
+	if CryoUtils.SteamAPIResponse != nil {
+		return
+	}
+	cache, err := readAppNameCache()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		CryoUtils.ErrorLog.Println(err)
+	}
+	if cache != nil && time.Since(cache.Updated) < AppNameCacheTTL {
+		CryoUtils.SteamAPIResponse = cache.Names
+		return
+	}
 
-	for i := range response.Applist.Apps {
-		gameMap[response.Applist.Apps[i].Appid] = response.Applist.Apps[i].Name
+	_, err = RefreshAppNames(ctx)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		// Stale names are better than none while offline
+		CryoUtils.SteamAPIResponse = make(map[int]string)
+		if cache != nil && cache.Names != nil {
+			CryoUtils.SteamAPIResponse = cache.Names
+		}
 	}
+}
 
-	return gameMap
+// Get a game's name from the Steam API, asking it again at most once per run for games that aren't in the cache.
+func getAPIGameName(ctx context.Context, appID int) string {
+	loadSteamAPIResponse(ctx)
+	name, ok := CryoUtils.SteamAPIResponse[appID]
+	if !ok && !appNamesRefreshed.Load() {
+		CryoUtils.InfoLog.Println("No cached name for", appID, "refreshing game names...")
+		_, err := RefreshAppNames(ctx)
+		if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+		}
+		name = CryoUtils.SteamAPIResponse[appID]
+	}
+	return name
 }
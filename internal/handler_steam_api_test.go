@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestQuerySteamAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"applist": {"apps": [{"appid": 10, "name": "Counter-Strike"}, ` +
+			`{"appid": 400, "name": "Portal"}, {"appid": 620, "name": "Portal 2"}, {"appid": 70, "name": ""}]}}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		wanted  map[int]bool
+		want    map[int]string
+		wantErr bool
+	}{
+		{name: "Only wanted games kept", path: "/", wanted: map[int]bool{400: true, 620: true, 1: true},
+			want: map[int]string{400: "Portal", 620: "Portal 2"}},
+		{name: "Nothing wanted", path: "/", wanted: nil, want: map[int]string{}},
+		{name: "Bad status", path: "/missing", wanted: map[int]bool{400: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := querySteamAPI(context.Background(), server.URL+tt.path, tt.wanted)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("querySteamAPI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("querySteamAPI() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -18,6 +18,8 @@ package internal
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -46,7 +48,7 @@ func getSwapFileLocation() (string, error) {
 			location := fields[0]
 			// If swapfile is a partition then return no swapfile found
 			if strings.HasPrefix(location, "/dev/") {
-				return "", fmt.Errorf("no swapfile found")
+				return "", ErrNoSwapFile
 			}
 			return location, nil
 		}
@@ -56,14 +58,14 @@ func getSwapFileLocation() (string, error) {
 		return DefaultSwapFileLocation, nil
 	}
 
-	return "", fmt.Errorf("no swapfile found")
+	return "", ErrNoSwapFile
 }
 
 // Get the current swap and swappiness values
 func getSwappinessValue() (int, error) {
-	cmd, err := exec.Command("sysctl", "vm.swappiness").Output()
+	cmd, err := runCommand(exec.Command("sysctl", "vm.swappiness"))
 	if err != nil {
-		return 100, fmt.Errorf("error getting current swappiness")
+		return 100, fmt.Errorf("error getting current swappiness: %w", err)
 	}
 	output := strings.Fields(string(cmd))
 	CryoUtils.InfoLog.Println("Found a swappiness of", output[2])
@@ -76,7 +78,7 @@ func getSwappinessValue() (int, error) {
 func getSwapFileSize() (int64, error) {
 	location, err := getSwapFileLocation()
 	if err != nil {
-		return DefaultSwapSizeBytes, fmt.Errorf("error getting swapfile location: %v", err)
+		return DefaultSwapSizeBytes, fmt.Errorf("error getting swapfile location: %w", err)
 	}
 
 	CryoUtils.SwapFileLocation = location
@@ -84,7 +86,7 @@ func getSwapFileSize() (int64, error) {
 	info, err := os.Stat(CryoUtils.SwapFileLocation)
 	if err != nil {
 		// Don't crash the program, just report the default size
-		return DefaultSwapSizeBytes, fmt.Errorf("error getting current swap file size")
+		return DefaultSwapSizeBytes, fmt.Errorf("error getting current swap file size: %w", err)
 	}
 	CryoUtils.InfoLog.Println("Found a swap file with a size of", info.Size())
 	return info.Size(), nil
@@ -96,7 +98,7 @@ func getAvailableSwapSizes() ([]string, error) {
 	currentSwapSize, _ := getSwapFileSize()
 	availableSpace, err := getFreeSpace("/home")
 	if err != nil {
-		return nil, fmt.Errorf("error getting available space in /home")
+		return nil, fmt.Errorf("error getting available space in /home: %w", err)
 	}
 
 	// Loop through the range of available sizes and create a list of viable options for the current Deck.
@@ -122,33 +124,108 @@ func getAvailableSwapSizes() ([]string, error) {
 // Disable swapping completely
 func disableSwap() error {
 	CryoUtils.InfoLog.Println("Disabling swap temporarily...")
-	_, err := exec.Command("sudo", "swapoff", "-a").Output()
+	_, err := runCommand(exec.Command("sudo", "swapoff", "-a"))
 	if err != nil {
-		return fmt.Errorf("error disabling swap")
+		var cmdErr *CommandError
+		// swapoff fails like this when the contents of swap won't fit in RAM
+		if errors.As(err, &cmdErr) && (strings.Contains(cmdErr.Stderr, "Cannot allocate memory") ||
+			strings.Contains(cmdErr.Stderr, "Device or resource busy")) {
+			return fmt.Errorf("error disabling swap: %w: %w", ErrSwapBusy, err)
+		}
+		return fmt.Errorf("error disabling swap: %w", err)
+	}
+	return nil
+}
+
+// Make sure a swap file of the given size, in GB, leaves enough room on /home to avoid boot loops.
+// This also looks up the swap file location, which the resize relies on.
+func checkSwapSpace(size int) error {
+	currentSwapSize, err := getSwapFileSize()
+	if errors.Is(err, ErrNoSwapFile) {
+		return err
+	}
+	// The stock size is always offered, it's the way back from a full drive
+	if size <= DefaultSwapSize {
+		return nil
+	}
+	availableSpace, err := getFreeSpace("/home")
+	if err != nil {
+		return err
+	}
+	needed := int64(size*GigabyteMultiplier + SpaceOverhead)
+	if needed >= availableSpace+currentSwapSize {
+		return fmt.Errorf("%w: a %dGB swap file needs %.2fGB free in /home, only %.2fGB is available",
+			ErrInsufficientSpace, size, float64(needed)/float64(GigabyteMultiplier),
+			float64(availableSpace+currentSwapSize)/float64(GigabyteMultiplier))
+	}
+	return nil
+}
+
+// Remember the sizes involved in a resize, so an interrupted one can be finished or undone. Returns the size the swap
+// file had before, in GB, 0 if unknown. Relies on checkSwapSpace having found the swap file.
+func recordSwapResize(size int) int {
+	var previous int
+	info, err := os.Stat(CryoUtils.SwapFileLocation)
+	if err == nil {
+		previous = int(info.Size() / int64(GigabyteMultiplier))
+		recordOperationDetail("previous_size", strconv.Itoa(previous))
 	}
-	return err
+	recordOperationDetail("size", strconv.Itoa(size))
+	return previous
 }
 
-// Resize the swap file to the provided size, in GB.
-func resizeSwapFile(size int) error {
+// Resize the swap file to the provided size, in GB. Cancelling the context stops dd part-way.
+func resizeSwapFile(ctx context.Context, size int) error {
 	locationArg := fmt.Sprintf("of=%s", CryoUtils.SwapFileLocation)
 	countArg := fmt.Sprintf("count=%d", size)
 
 	CryoUtils.InfoLog.Println("Resizing swap to", size, "GB...")
 	// Use dd to write zeroes, reevaluate using Go directly in the future
-	_, err := exec.Command("sudo", "dd", "if=/dev/zero", locationArg, "bs=1G", countArg, "status=progress").Output()
+	cmd := exec.CommandContext(ctx, "sudo", "dd", "if=/dev/zero", locationArg, "bs=1G", countArg, "status=progress")
+	// sudo passes SIGINT on to dd, killing sudo outright would leave dd running.
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	_, err := runCommand(cmd)
+	if ctx.Err() != nil {
+		CryoUtils.InfoLog.Println("Swap resize cancelled")
+		return ctx.Err()
+	}
 	if err != nil {
-		return fmt.Errorf("error resizing %s", CryoUtils.SwapFileLocation)
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) && strings.Contains(cmdErr.Stderr, "No space left on device") {
+			return fmt.Errorf("error resizing %s: %w: %w", CryoUtils.SwapFileLocation, ErrInsufficientSpace, err)
+		}
+		return fmt.Errorf("error resizing %s: %w", CryoUtils.SwapFileLocation, err)
 	}
 	return nil
 }
 
+// Put back a working swap file of the size it had before, in GB, after a cancelled resize left a partial one behind.
+// Falls back to the stock size if the previous size is unknown, the same way a rollback does.
+// This is the safe-stop point for swap resizes, and deliberately ignores cancellation.
+func restorePreviousSwapFile(previous int) error {
+	if previous < DefaultSwapSize {
+		previous = DefaultSwapSize
+	}
+	CryoUtils.InfoLog.Println("Restoring a", previous, "GB swap file after cancellation...")
+	err := resizeSwapFile(context.Background(), previous)
+	if err != nil {
+		return err
+	}
+	err = setSwapPermissions()
+	if err != nil {
+		return err
+	}
+	return initNewSwapFile()
+}
+
 // Set swap permissions to a valid value.
 func setSwapPermissions() error {
 	CryoUtils.InfoLog.Println("Setting permissions on", CryoUtils.SwapFileLocation, "to 0600...")
-	_, err := exec.Command("sudo", "chmod", "600", CryoUtils.SwapFileLocation).Output()
+	_, err := runCommand(exec.Command("sudo", "chmod", "600", CryoUtils.SwapFileLocation))
 	if err != nil {
-		return fmt.Errorf("error setting permissions on %s", CryoUtils.SwapFileLocation)
+		return fmt.Errorf("error setting permissions on %s: %w", CryoUtils.SwapFileLocation, err)
 	}
 	return nil
 }
@@ -156,13 +233,13 @@ func setSwapPermissions() error {
 // Enable swapping on the newly resized file.
 func initNewSwapFile() error {
 	CryoUtils.InfoLog.Println("Enabling swap on", CryoUtils.SwapFileLocation, "...")
-	_, err := exec.Command("sudo", "mkswap", CryoUtils.SwapFileLocation).Output()
+	_, err := runCommand(exec.Command("sudo", "mkswap", CryoUtils.SwapFileLocation))
 	if err != nil {
-		return fmt.Errorf("error creating swap on %s", CryoUtils.SwapFileLocation)
+		return fmt.Errorf("error creating swap on %s: %w", CryoUtils.SwapFileLocation, err)
 	}
-	_, err = exec.Command("sudo", "swapon", CryoUtils.SwapFileLocation).Output()
+	_, err = runCommand(exec.Command("sudo", "swapon", CryoUtils.SwapFileLocation))
 	if err != nil {
-		return fmt.Errorf("error enabling swap on %s", CryoUtils.SwapFileLocation)
+		return fmt.Errorf("error enabling swap on %s: %w", CryoUtils.SwapFileLocation, err)
 	}
 	return nil
 }
@@ -0,0 +1,172 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// How thoroughly a copy is checked before its source is deleted.
+const (
+	VerifyOff      = "off"
+	VerifyMetadata = "metadata"
+	VerifyFull     = "full"
+)
+
+// VerifyModes Every verification mode, from least to most thorough
+var VerifyModes = []string{VerifyOff, VerifyMetadata, VerifyFull}
+
+func isVerifyMode(mode string) bool {
+	return Contains(VerifyModes, mode)
+}
+
+// VerificationError A copy that doesn't match its source
+type VerificationError struct {
+	AppID      string
+	Mismatches []string
+}
+
+func (e *VerificationError) Error() string {
+	shown := e.Mismatches
+	if len(shown) > VerificationReportLimit {
+		shown = shown[:VerificationReportLimit]
+	}
+	message := fmt.Sprintf("copy of %s doesn't match its source, %d files differ:\n%s",
+		e.AppID, len(e.Mismatches), strings.Join(shown, "\n"))
+	if len(shown) < len(e.Mismatches) {
+		message += fmt.Sprintf("\n...and %d more, see the log for the full list", len(e.Mismatches)-len(shown))
+	}
+	return message
+}
+
+func (e *VerificationError) Is(target error) bool {
+	return target == ErrVerificationFailed
+}
+
+// Compare every file under src against its copy under dest, returning a description of each difference.
+// Files only in dest are ignored, the copy may have been merged into existing data.
+func compareTrees(ctx context.Context, src string, dest string, mode string) ([]string, error) {
+	var mismatches []string
+	err := filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		relative, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		mismatch, err := compareFile(path, filepath.Join(dest, relative), mode)
+		if err != nil {
+			return err
+		}
+		if mismatch != "" {
+			mismatches = append(mismatches, filepath.Join(filepath.Base(src), relative)+": "+mismatch)
+		}
+		return nil
+	})
+	return mismatches, err
+}
+
+// Compare a single file with its copy, returning what differs or an empty string if they match.
+func compareFile(src string, dest string, mode string) (string, error) {
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return "", err
+	}
+	destInfo, err := os.Lstat(dest)
+	if errors.Is(err, os.ErrNotExist) {
+		return "missing", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if srcInfo.Mode().Type() != destInfo.Mode().Type() {
+		return fmt.Sprintf("type %s, expected %s", destInfo.Mode().Type(), srcInfo.Mode().Type()), nil
+	}
+	switch {
+	case srcInfo.Mode()&os.ModeSymlink != 0:
+		srcTarget, err := os.Readlink(src)
+		if err != nil {
+			return "", err
+		}
+		destTarget, err := os.Readlink(dest)
+		if err != nil {
+			return "", err
+		}
+		if srcTarget != destTarget {
+			return fmt.Sprintf("links to %s, expected %s", destTarget, srcTarget), nil
+		}
+		return "", nil
+	case !srcInfo.Mode().IsRegular() && !srcInfo.IsDir():
+		// Sockets, pipes and devices aren't copied meaningfully, their presence is enough.
+		return "", nil
+	}
+
+	if srcInfo.Mode().Perm() != destInfo.Mode().Perm() {
+		return fmt.Sprintf("mode %s, expected %s", destInfo.Mode().Perm(), srcInfo.Mode().Perm()), nil
+	}
+	if srcInfo.IsDir() {
+		return "", nil
+	}
+	if srcInfo.Size() != destInfo.Size() {
+		return fmt.Sprintf("size %d, expected %d", destInfo.Size(), srcInfo.Size()), nil
+	}
+	if mode != VerifyFull {
+		return "", nil
+	}
+
+	srcHash, err := hashFile(src)
+	if err != nil {
+		return "", err
+	}
+	destHash, err := hashFile(dest)
+	if err != nil {
+		return "", err
+	}
+	if srcHash != destHash {
+		return "contents differ", nil
+	}
+	return "", nil
+}
+
+// Hash a file's contents with xxhash, fast enough to check whole shader caches.
+func hashFile(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	hash := xxhash.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return 0, err
+	}
+	return hash.Sum64(), nil
+}
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareTrees(t *testing.T) {
+	type args struct {
+		change func(dest string)
+		mode   string
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{
+			name: "Identical",
+			args: args{
+				change: func(string) {},
+				mode:   VerifyFull,
+			},
+			want: 0,
+		},
+		{
+			name: "Missing file",
+			args: args{
+				change: func(dest string) { _ = os.Remove(filepath.Join(dest, "pfx", "user.reg")) },
+				mode:   VerifyMetadata,
+			},
+			want: 1,
+		},
+		{
+			name: "Different size",
+			args: args{
+				change: func(dest string) { _ = os.WriteFile(filepath.Join(dest, "pfx", "user.reg"), []byte("x"), 0644) },
+				mode:   VerifyMetadata,
+			},
+			want: 1,
+		},
+		{
+			name: "Different mode",
+			args: args{
+				change: func(dest string) { _ = os.Chmod(filepath.Join(dest, "pfx", "user.reg"), 0600) },
+				mode:   VerifyMetadata,
+			},
+			want: 1,
+		},
+		{
+			name: "Different contents, metadata only",
+			args: args{
+				change: func(dest string) { _ = os.WriteFile(filepath.Join(dest, "pfx", "user.reg"), []byte("REGEDIT"), 0644) },
+				mode:   VerifyMetadata,
+			},
+			want: 0,
+		},
+		{
+			name: "Different contents, full",
+			args: args{
+				change: func(dest string) { _ = os.WriteFile(filepath.Join(dest, "pfx", "user.reg"), []byte("REGEDIT"), 0644) },
+				mode:   VerifyFull,
+			},
+			want: 1,
+		},
+		{
+			name: "Different symlink",
+			args: args{
+				change: func(dest string) {
+					_ = os.Remove(filepath.Join(dest, "pfx", "link"))
+					_ = os.Symlink("elsewhere", filepath.Join(dest, "pfx", "link"))
+				},
+				mode: VerifyMetadata,
+			},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dest := t.TempDir(), t.TempDir()
+			for _, root := range []string{src, dest} {
+				_ = os.MkdirAll(filepath.Join(root, "pfx"), 0755)
+				_ = os.WriteFile(filepath.Join(root, "pfx", "user.reg"), []byte("WINE123"), 0644)
+				_ = os.Symlink("user.reg", filepath.Join(root, "pfx", "link"))
+			}
+			tt.args.change(dest)
+
+			got, err := compareTrees(context.Background(), src, dest, tt.args.mode)
+			if err != nil {
+				t.Fatalf("compareTrees() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("compareTrees() = %v, want %d differences", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package internal
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+// The handlers log as they go, so give them loggers that discard everything.
+func TestMain(m *testing.M) {
+	CryoUtils.InfoLog = log.New(io.Discard, "", 0)
+	CryoUtils.ErrorLog = log.New(io.Discard, "", 0)
+	os.Exit(m.Run())
+}
@@ -0,0 +1,130 @@
+// CryoUtilities
+// Copyright (C) 2023 CryoByte33 and contributors to the CryoUtilities project
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Settings Preferences that apply to both the GUI and the CLI
+type Settings struct {
+	VerifyMode     string `json:"verify_mode"`
+	QuarantineDays int    `json:"quarantine_days"`
+}
+
+// Get the default settings, used for anything missing from the settings file.
+func defaultSettings() Settings {
+	return Settings{
+		VerifyMode:     DefaultVerifyMode,
+		QuarantineDays: DefaultQuarantineDays,
+	}
+}
+
+// Load the settings file, falling back to the defaults if it doesn't exist yet.
+func loadSettings() (Settings, error) {
+	settings := defaultSettings()
+	contents, err := os.ReadFile(SettingsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	} else if err != nil {
+		return settings, err
+	}
+	err = json.Unmarshal(contents, &settings)
+	if err != nil {
+		return defaultSettings(), fmt.Errorf("error reading %s: %w", SettingsPath, err)
+	}
+	return settings, nil
+}
+
+// Save the settings file, readable by both the GUI user and root.
+func saveSettings(settings Settings) error {
+	contents, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	_ = os.MkdirAll(InstallDirectory, 0777)
+	err = os.WriteFile(SettingsPath, contents, 0666)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		return fmt.Errorf("error saving %s: %w", SettingsPath, err)
+	}
+	return nil
+}
+
+// GetVerifyMode Get how copied game data is verified, the default if the settings can't be read.
+func GetVerifyMode() string {
+	settings, err := loadSettings()
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
+	if !isVerifyMode(settings.VerifyMode) {
+		return DefaultVerifyMode
+	}
+	return settings.VerifyMode
+}
+
+// SetVerifyMode Change how copied game data is verified before the source is deleted.
+func SetVerifyMode(mode string) error {
+	if !isVerifyMode(mode) {
+		return fmt.Errorf("%w: verification mode must be one of %v", ErrInvalidArgument, VerifyModes)
+	}
+	settings, err := loadSettings()
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
+	settings.VerifyMode = mode
+	err = saveSettings(settings)
+	if err != nil {
+		return err
+	}
+	CryoUtils.InfoLog.Println("Verification mode set to", mode)
+	return nil
+}
+
+// GetQuarantineDays Get how many days cleaned up data is kept, the default if the settings can't be read.
+func GetQuarantineDays() int {
+	settings, err := loadSettings()
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
+	if settings.QuarantineDays < 0 {
+		return DefaultQuarantineDays
+	}
+	return settings.QuarantineDays
+}
+
+// SetQuarantineDays Change how many days cleaned up data is kept before it's deleted, 0 keeps it until the quarantine
+// is emptied.
+func SetQuarantineDays(days int) error {
+	if days < 0 {
+		return fmt.Errorf("%w: quarantine days can't be negative", ErrInvalidArgument)
+	}
+	settings, err := loadSettings()
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
+	settings.QuarantineDays = days
+	err = saveSettings(settings)
+	if err != nil {
+		return err
+	}
+	CryoUtils.InfoLog.Println("Quarantine days set to", days)
+	return nil
+}
@@ -17,6 +17,7 @@
 package internal
 
 import (
+	"fmt"
 	"os/exec"
 	"time"
 
@@ -82,7 +83,7 @@ func testAuth(password string) error {
 	stdin.Close()
 	err = cmd.Wait()
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrAuth, err)
 	}
 	return nil
 }
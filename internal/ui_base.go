@@ -18,16 +18,41 @@ package internal
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
-func InitUI() {
+func InitUI(args []string) {
+	// Make sure only one GUI is running, otherwise pass the arguments along and exit.
+	listener, err := listenForInstances()
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to become the running instance:", err)
+		activated, err := ActivateRunningInstance(args)
+		if activated {
+			if err != nil {
+				CryoUtils.ErrorLog.Println(err)
+			}
+			return
+		}
+	}
+	if len(args) > 0 {
+		CryoUtils.PendingTab = args[0]
+	}
+
+	// Panics in Fyne's goroutines can't be recovered, keep their output for the next launch
+	err = CapturePanicOutput()
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to capture panic output:", err)
+	}
+
 	// Create a Fyne application
 	screenSizer := NewScreenSizer()
 	screenSizer.UpdateScaleForActiveMonitor()
@@ -39,6 +64,10 @@ func InitUI() {
 	title := "CryoUtilities " + CurrentVersionNumber
 	CryoUtils.MainWindow = fyneApp.NewWindow(title)
 	CryoUtils.makeUI()
+	if listener != nil {
+		go CryoUtils.serveInstanceRequests(listener)
+		defer listener.Close()
+	}
 	CryoUtils.MainWindow.CenterOnScreen()
 	CryoUtils.MainWindow.ShowAndRun()
 }
@@ -79,9 +108,47 @@ func (app *Config) mainUI() {
 		container.NewTabItemWithIcon("VRAM", theme.ViewFullScreenIcon(), app.vramTab()),
 	)
 	tabs.SetTabLocation(container.TabLocationTop)
+	app.MainTabs = tabs
+
+	// Open the tab requested on the command line or by another launch
+	if app.PendingTab != "" {
+		err := app.selectTab(app.PendingTab)
+		if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+		}
+	}
+
+	// Shown only while another process is changing the system
+	app.LockStatusText = canvas.NewText("", Red)
+	app.LockStatusText.Alignment = fyne.TextAlignCenter
+	app.LockStatusText.Hide()
 
-	finalContent := container.NewVBox(tabs)
+	finalContent := container.NewVBox(tabs, app.LockStatusText)
 	app.MainWindow.SetContent(finalContent)
+
+	app.refreshLockContent()
+	go app.watchOperationLock()
+	go app.watchDrives()
+
+	// Now that sudo works, deal with whatever the last session left behind
+	app.offerCrashRecovery()
+}
+
+// Select the main window tab with the given name, case-insensitive.
+func (app *Config) selectTab(name string) error {
+	// Tabs only exist after authentication, so remember the choice until then.
+	if app.MainTabs == nil {
+		app.PendingTab = name
+		return nil
+	}
+	for _, item := range app.MainTabs.Items {
+		if strings.EqualFold(item.Text, name) {
+			app.MainTabs.Select(item)
+			app.PendingTab = ""
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown tab %q", name)
 }
 
 func (app *Config) authUI() {
@@ -17,6 +17,7 @@
 package internal
 
 import (
+	"context"
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
@@ -57,7 +58,7 @@ func (app *Config) homeTab() *fyne.Container {
 			"Page Lock Unfairness: " + RecommendedPageLockUnfairness + "\n" +
 			"Shared Memory in Huge Pages: Enabled")
 
-	recommendedButton := widget.NewButton("Recommended", func() {
+	app.RecommendedButton = widget.NewButton("Recommended", func() {
 		progressGroup := container.NewVBox(
 			canvas.NewText("Applying recommended settings...", White),
 			actionText,
@@ -65,7 +66,9 @@ func (app *Config) homeTab() *fyne.Container {
 		modal := widget.NewModalPopUp(progressGroup, CryoUtils.MainWindow.Canvas())
 		modal.Show()
 		renewSudoAuth()
-		err := UseRecommendedSettings()
+		err := WithOperationLock(OperationSwap, func() error {
+			return UseRecommendedSettings(context.Background())
+		})
 		if err != nil {
 			presentErrorInUI(err, CryoUtils.MainWindow)
 		}
@@ -77,14 +80,16 @@ func (app *Config) homeTab() *fyne.Container {
 			CryoUtils.MainWindow,
 		)
 	})
-	stockButton := widget.NewButton("Stock", func() {
+	app.StockButton = widget.NewButton("Stock", func() {
 		progressText := canvas.NewText("Reverting to stock settings...", White)
 		progressBar := widget.NewProgressBarInfinite()
 		progressGroup := container.NewVBox(progressText, progressBar)
 		modal := widget.NewModalPopUp(progressGroup, CryoUtils.MainWindow.Canvas())
 		modal.Show()
 		renewSudoAuth()
-		err := UseStockSettings()
+		err := WithOperationLock(OperationSwap, func() error {
+			return UseStockSettings(context.Background())
+		})
 		if err != nil {
 			presentErrorInUI(err, CryoUtils.MainWindow)
 		}
@@ -98,9 +103,9 @@ func (app *Config) homeTab() *fyne.Container {
 	})
 
 	recommendedSettings := widget.NewCard("Recommended Settings", "Set all settings to "+
-		"CryoByte33's recommendations.", recommendedButton)
+		"CryoByte33's recommendations.", app.RecommendedButton)
 	stockSettings := widget.NewCard("Stock Settings", "Reset all settings to Valve defaults, excludes "+
-		"'Game Data' tab/locations.", stockButton)
+		"'Game Data' tab/locations.", app.StockButton)
 
 	homeVBox := container.NewVBox(
 		welcomeText,
@@ -118,17 +123,17 @@ func (app *Config) swapTab() *fyne.Container {
 	app.SwapText = canvas.NewText("Swap File Size: Unknown", Gray)
 	app.SwappinessText = canvas.NewText("Swappiness: Unknown", Gray)
 	// Main content including buttons to resize swap and change swappiness
-	swapResizeButton := widget.NewButton("Resize", func() {
+	app.SwapResizeButton = widget.NewButton("Resize", func() {
 		swapSizeWindow()
 		app.refreshSwapContent()
 	})
-	swappinessChangeButton := widget.NewButton("Change", func() {
+	app.SwappinessChangeButton = widget.NewButton("Change", func() {
 		swappinessWindow()
 		app.refreshSwappinessContent()
 	})
 
-	swapCard := widget.NewCard("Swap File", "Resize the swap file.", swapResizeButton)
-	swappinessCard := widget.NewCard("Swappiness", "Change the swappiness value.", swappinessChangeButton)
+	swapCard := widget.NewCard("Swap File", "Resize the swap file.", app.SwapResizeButton)
+	swappinessCard := widget.NewCard("Swappiness", "Change the swappiness value.", app.SwappinessChangeButton)
 
 	// Swap info gathering
 	app.refreshSwapContent()
@@ -157,7 +162,7 @@ func (app *Config) swapTab() *fyne.Container {
 // Game Data tab to move and delete prefixes and shadercache.
 func (app *Config) storageTab() *fyne.Container {
 	// These can take a minute to come up, so create a loading bar to show things are happening.
-	syncDataButton := widget.NewButton("Sync", func() {
+	app.SyncDataButton = widget.NewButton("Sync", func() {
 		progressText := canvas.NewText("Calculating device status...", White)
 		progressBar := widget.NewProgressBarInfinite()
 		progressGroup := container.NewVBox(progressText, progressBar)
@@ -166,7 +171,16 @@ func (app *Config) storageTab() *fyne.Container {
 		syncGameDataWindow()
 		modal.Hide()
 	})
-	cleanupDataButton := widget.NewButton("Clean", func() {
+	app.MoveGamesButton = widget.NewButton("Move Games", func() {
+		progressText := canvas.NewText("Finding game data...", White)
+		progressBar := widget.NewProgressBarInfinite()
+		progressGroup := container.NewVBox(progressText, progressBar)
+		modal := widget.NewModalPopUp(progressGroup, CryoUtils.MainWindow.Canvas())
+		modal.Show()
+		moveGamesWindow()
+		modal.Hide()
+	})
+	app.CleanupDataButton = widget.NewButton("Clean", func() {
 		progressText := canvas.NewText("Calculating device status...", White)
 		progressBar := widget.NewProgressBarInfinite()
 		progressGroup := container.NewVBox(progressText, progressBar)
@@ -176,14 +190,56 @@ func (app *Config) storageTab() *fyne.Container {
 		modal.Hide()
 	})
 
+	// How thoroughly copies are checked before the originals are deleted
+	verifySelect := widget.NewSelect(VerifyModes, func(mode string) {
+		err := SetVerifyMode(mode)
+		if err != nil {
+			presentErrorInUI(err, CryoUtils.MainWindow)
+		}
+	})
+	verifySelect.Selected = GetVerifyMode()
+	verifyBox := container.NewHBox(widget.NewLabel("Verify copies:"), verifySelect)
+
 	syncData := widget.NewCard("Sync Game Data", "Sync prefix and shaders to the device where the game "+
-		"is installed", syncDataButton)
-	cleanStaleData := widget.NewCard("Delete Game Data", "Delete prefixes and shaders for selected games.",
-		cleanupDataButton)
+		"is installed", container.NewBorder(nil, nil, nil, verifyBox,
+		container.NewGridWithColumns(2, app.SyncDataButton, app.MoveGamesButton)))
+	app.QuarantineButton = widget.NewButton("Quarantine", func() {
+		quarantineWindow()
+	})
+	cleanStaleData := widget.NewCard("Clean Game Data", "Set aside prefixes and shaders for selected games, "+
+		"restore or delete them for good.", container.NewGridWithColumns(2, app.CleanupDataButton,
+		app.QuarantineButton))
+
+	app.RepairLinksButton = widget.NewButton("Repair", func() {
+		linkRepairWindow()
+	})
+	repairLinks := widget.NewCard("Repair Game Data Links", "Fix links left broken by a removed or "+
+		"reformatted card.", app.RepairLinksButton)
+
+	app.BackupsButton = widget.NewButton("Backups", func() {
+		backupsWindow()
+	})
+	saveBackups := widget.NewCard("Save Backups", "Back up the saves in a game's prefix, or restore them.",
+		app.BackupsButton)
+
+	app.StorageReportButton = widget.NewButton("Report", func() {
+		progressText := canvas.NewText("Measuring game data...", White)
+		progressBar := widget.NewProgressBarInfinite()
+		progressGroup := container.NewVBox(progressText, progressBar)
+		modal := widget.NewModalPopUp(progressGroup, CryoUtils.MainWindow.Canvas())
+		modal.Show()
+		storageReportWindow()
+		modal.Hide()
+	})
+	storageReport := widget.NewCard("Storage Report", "See how much space each game's prefix and shaders "+
+		"take.", app.StorageReportButton)
 
 	gameDataVBox := container.NewVBox(
 		syncData,
 		cleanStaleData,
+		saveBackups,
+		repairLinks,
+		storageReport,
 	)
 	app.GameDataContainer = gameDataVBox
 
@@ -200,7 +256,7 @@ func (app *Config) memoryTab() *fyne.Container {
 
 	CryoUtils.HugePagesButton = widget.NewButton("Enable HugePages", func() {
 		renewSudoAuth()
-		err := ToggleHugePages()
+		err := WithOperationLock(OperationTweaks, ToggleHugePages)
 		if err != nil {
 			presentErrorInUI(err, CryoUtils.MainWindow)
 		}
@@ -209,7 +265,7 @@ func (app *Config) memoryTab() *fyne.Container {
 
 	CryoUtils.ShMemButton = widget.NewButton("Enable Shared Memory in THP", func() {
 		renewSudoAuth()
-		err := ToggleShMem()
+		err := WithOperationLock(OperationTweaks, ToggleShMem)
 		if err != nil {
 			presentErrorInUI(err, CryoUtils.MainWindow)
 		}
@@ -218,7 +274,7 @@ func (app *Config) memoryTab() *fyne.Container {
 
 	CryoUtils.CompactionProactivenessButton = widget.NewButton("Set Compaction Proactiveness", func() {
 		renewSudoAuth()
-		err := ToggleCompactionProactiveness()
+		err := WithOperationLock(OperationTweaks, ToggleCompactionProactiveness)
 		if err != nil {
 			presentErrorInUI(err, CryoUtils.MainWindow)
 		}
@@ -227,7 +283,7 @@ func (app *Config) memoryTab() *fyne.Container {
 
 	CryoUtils.DefragButton = widget.NewButton("Disable Huge Page Defragmentation", func() {
 		renewSudoAuth()
-		err := ToggleDefrag()
+		err := WithOperationLock(OperationTweaks, ToggleDefrag)
 		if err != nil {
 			presentErrorInUI(err, CryoUtils.MainWindow)
 		}
@@ -236,7 +292,7 @@ func (app *Config) memoryTab() *fyne.Container {
 
 	CryoUtils.PageLockUnfairnessButton = widget.NewButton("Set Page Lock Unfairness", func() {
 		renewSudoAuth()
-		err := TogglePageLockUnfairness()
+		err := WithOperationLock(OperationTweaks, TogglePageLockUnfairness)
 		if err != nil {
 			presentErrorInUI(err, CryoUtils.MainWindow)
 		}
@@ -17,13 +17,20 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
+	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type GameStatus struct {
@@ -31,19 +38,36 @@ type GameStatus struct {
 	IsInstalled bool
 }
 
-// Show an error message over the main window.
+// Show an error message over the main window, worded for the user where the error type is known.
 func presentErrorInUI(err error, win fyne.Window) {
 	CryoUtils.ErrorLog.Println(err)
-	dialog.ShowError(err, win)
+	dialog.ShowError(errors.New(friendlyErrorMessage(err)), win)
+}
+
+// Show a modal progress popup with a Cancel button that cancels the returned context.
+func showCancellableProgress(title string, progress fyne.CanvasObject, win fyne.Window) (context.Context,
+	context.CancelFunc, *widget.PopUp) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var cancelButton *widget.Button
+	cancelButton = widget.NewButton("Cancel", func() {
+		CryoUtils.InfoLog.Println("Cancel requested:", title)
+		cancelButton.SetText("Cancelling, please wait...")
+		cancelButton.Disable()
+		cancel()
+	})
+	modal := widget.NewModalPopUp(container.NewVBox(canvas.NewText(title, nil), progress, cancelButton),
+		win.Canvas())
+	modal.Show()
+	return ctx, cancel, modal
 }
 
 // Create a CheckGroup of game data to allow for selection.
-func createGameDataList() (*widget.CheckGroup, error) {
+func createGameDataList(ctx context.Context) (*widget.CheckGroup, error) {
 	cleanupList := widget.NewCheckGroup([]string{}, func(strings []string) {})
 	cleanupList.Enable()
 	cleanupList.Refresh()
 
-	localGames, err := getLocalGameList()
+	localGames, err := getLocalGameList(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -57,10 +81,17 @@ func createGameDataList() (*widget.CheckGroup, error) {
 	// Sort the slice
 	sort.Ints(sortedMap)
 
+	// Flag each game by where its saves are, so games with saves only on the Deck stand out
+	var appIDs []string
+	for _, appID := range sortedMap {
+		appIDs = append(appIDs, strconv.Itoa(appID))
+	}
+	statuses := GetCloudStatus(appIDs)
+
 	// For each entry in the completed list, add an entry to the check group to return
 	for key := range sortedMap {
-		// Skips non-game prefixes
-		if sortedMap[key] == 0 || sortedMap[key] >= SteamGameMaxInteger {
+		// Skips non-game prefixes, and tools like Proton
+		if !isManagedApp(sortedMap[key]) {
 			continue
 		}
 
@@ -74,10 +105,11 @@ func createGameDataList() (*widget.CheckGroup, error) {
 			gameStr = "???"
 		}
 
+		status := statuses[strconv.Itoa(sortedMap[key])]
 		if localGames[sortedMap[key]].IsInstalled {
-			optionStr = fmt.Sprintf("%d - %s - Installed", sortedMap[key], gameStr)
+			optionStr = fmt.Sprintf("%d - %s - Installed - %s", sortedMap[key], gameStr, status)
 		} else {
-			optionStr = fmt.Sprintf("%d - %s - Not Installed", sortedMap[key], gameStr)
+			optionStr = fmt.Sprintf("%d - %s - Not Installed - %s", sortedMap[key], gameStr, status)
 		}
 		cleanupList.Append(optionStr)
 	}
@@ -85,17 +117,7 @@ func createGameDataList() (*widget.CheckGroup, error) {
 	return cleanupList, nil
 }
 
-func getLocalGameList() (map[int]GameStatus, error) {
-	// Use the cached API Response if already present
-	if CryoUtils.SteamAPIResponse == nil {
-		// Make a map of all games stored in the steam API
-		steamResponse, err := querySteamAPI()
-		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
-		}
-		CryoUtils.SteamAPIResponse = generateGameMap(steamResponse)
-	}
-
+func getLocalGameList(ctx context.Context) (map[int]GameStatus, error) {
 	// Get a list of games that Steam classifies as installed
 	libraries, err := findDataFolders()
 	if err != nil {
@@ -135,11 +157,19 @@ func getLocalGameList() (map[int]GameStatus, error) {
 	for i := range storage {
 		intGame, _ := strconv.Atoi(storage[i])
 		localGames[intGame] = GameStatus{
-			GameName:    CryoUtils.SteamAPIResponse[intGame],
+			GameName:    getGameName(ctx, intGame),
 			IsInstalled: false,
 		}
 	}
 
+	// Shortcuts are installed for as long as they're in Steam
+	for appID := range getShortcuts() {
+		if val, keyExists := localGames[appID]; keyExists {
+			val.IsInstalled = true
+			localGames[appID] = val
+		}
+	}
+
 	// Loop through each library location's installed games
 	for i := range libraries {
 		for j := range libraries[i].InstalledGames {
@@ -155,51 +185,60 @@ func getLocalGameList() (map[int]GameStatus, error) {
 }
 
 // Get data to move values as canvas elements.
-func getDataToMoveUI(data DataToMove) (*widget.List, *widget.List, error) {
+func getDataToMoveUI(ctx context.Context, data DataToMove) (*widget.List, *widget.List, error) {
 	var leftList, rightList *widget.List
 
-	// Use the cached API Response if already present
-	if CryoUtils.SteamAPIResponse == nil {
-		// Make a map of all games stored in the steam API
-		steamResponse, err := querySteamAPI()
-		if err != nil {
-			CryoUtils.ErrorLog.Println(err)
-		}
-		CryoUtils.SteamAPIResponse = generateGameMap(steamResponse)
+	// Get lists of data to move, noting which kinds of each game's data are moving
+	rightGames, leftGames := data.right.games(), data.left.games()
+	// Look the names up front, the API can't be waited on while the lists draw
+	names := make(map[string]string)
+	for _, game := range append(rightGames, leftGames...) {
+		gameInt, _ := strconv.Atoi(game)
+		names[game] = getGameName(ctx, gameInt)
 	}
-
-	// Get lists of data to move
 	leftList = widget.NewList(
 		func() int {
-			return len(data.right)
+			return len(rightGames)
 		},
 		func() fyne.CanvasObject {
 			return widget.NewLabel("Left Side")
 		},
 		func(i widget.ListItemID, o fyne.CanvasObject) {
-			gameInt, _ := strconv.Atoi(data.right[i])
-			gameName := CryoUtils.SteamAPIResponse[gameInt]
+			gameName := names[rightGames[i]]
 
-			o.(*widget.Label).SetText(data.right[i] + " - " + gameName)
+			o.(*widget.Label).SetText(rightGames[i] + " - " + gameName + " - " + data.right.describe(rightGames[i]))
 		})
 
 	rightList = widget.NewList(
 		func() int {
-			return len(data.left)
+			return len(leftGames)
 		},
 		func() fyne.CanvasObject {
 			return widget.NewLabel("Right Side")
 		},
 		func(i widget.ListItemID, o fyne.CanvasObject) {
-			gameInt, _ := strconv.Atoi(data.left[i])
-			gameName := CryoUtils.SteamAPIResponse[gameInt]
+			gameName := names[leftGames[i]]
 
-			o.(*widget.Label).SetText(data.left[i] + " - " + gameName)
+			o.(*widget.Label).SetText(leftGames[i] + " - " + gameName + " - " + data.left.describe(leftGames[i]))
 		})
 
 	return leftList, rightList, nil
 }
 
+// Create a card summarising each drive's usage before and after a move, and the games left out of it.
+func createDriveSpaceCard(spaces []DriveSpace) *widget.Card {
+	spaceSummary := container.NewVBox()
+	for _, space := range spaces {
+		spaceSummary.Add(widget.NewLabel(space.String()))
+		if len(space.Trimmed) != 0 {
+			spaceSummary.Add(canvas.NewText(fmt.Sprintf("Not enough space on %s, leaving these where they "+
+				"are: %s", getDriveName(space.Drive), strings.Join(space.Trimmed, ", ")), Red))
+		}
+	}
+	return widget.NewCard("Drive Usage", fmt.Sprintf("%s is kept free on each drive",
+		GetHumanByteSize(int64(SpaceOverhead))), spaceSummary)
+}
+
 func (app *Config) refreshSwapContent() {
 	app.InfoLog.Println("Refreshing Swap data...")
 	swap, err := getSwapFileSize()
@@ -341,3 +380,129 @@ func (app *Config) refreshAllContent() {
 	app.refreshPageLockUnfairnessContent()
 	app.refreshVRAMContent()
 }
+
+// Every button that starts an operation covered by the operation lock.
+func (app *Config) lockableButtons() []*widget.Button {
+	var buttons []*widget.Button
+	for _, button := range []*widget.Button{
+		app.RecommendedButton,
+		app.StockButton,
+		app.SwapResizeButton,
+		app.SwappinessChangeButton,
+		app.HugePagesButton,
+		app.ShMemButton,
+		app.CompactionProactivenessButton,
+		app.DefragButton,
+		app.PageLockUnfairnessButton,
+		app.SyncDataButton,
+		app.MoveGamesButton,
+		app.CleanupDataButton,
+		app.QuarantineButton,
+		app.BackupsButton,
+		app.RepairLinksButton,
+	} {
+		if button != nil {
+			buttons = append(buttons, button)
+		}
+	}
+	return buttons
+}
+
+// Disable anything that changes the system while another process holds the operation lock.
+func (app *Config) refreshLockContent() {
+	holder, err := getLockHolder()
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+		return
+	}
+
+	lockedElsewhere := holder != nil && holder.PID != os.Getpid()
+	for _, button := range app.lockableButtons() {
+		if lockedElsewhere {
+			button.Disable()
+		} else {
+			button.Enable()
+		}
+	}
+
+	if lockedElsewhere {
+		app.LockStatusText.Text = fmt.Sprintf("A %s is running in another process (PID %d), please wait...",
+			holder.Operation, holder.PID)
+		app.LockStatusText.Show()
+	} else {
+		app.LockStatusText.Hide()
+	}
+	app.LockStatusText.Refresh()
+}
+
+// Keep the lock status up to date for as long as the GUI is running.
+func (app *Config) watchOperationLock() {
+	defer RecoverAndReport()
+	ticker := time.NewTicker(LockPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		app.refreshLockContent()
+	}
+}
+
+// Watch for drives being mounted, so links to a known card mounted somewhere new are fixed right away.
+func (app *Config) watchDrives() {
+	defer RecoverAndReport()
+	ticker := time.NewTicker(DrivePollInterval)
+	defer ticker.Stop()
+	var lastKey string
+	for ; true; <-ticker.C {
+		key, err := getMountKey()
+		if err != nil || key == lastKey {
+			continue
+		}
+		err = UpdateDriveRegistry()
+		if errors.Is(err, ErrOperationLocked) {
+			// Try again once the other operation is done
+			continue
+		} else if err != nil {
+			CryoUtils.ErrorLog.Println("Unable to update drive registry:", err)
+		}
+		lastKey = key
+	}
+}
+
+// Explain where cleaned up data goes, and for how long it can be restored.
+func getQuarantineNotice() string {
+	keep := "until the quarantine is emptied"
+	if days := GetQuarantineDays(); days > 0 {
+		keep = fmt.Sprintf("for %d days", days)
+	}
+	return "Each game's saves are backed up first. Its data will be moved to the quarantine\n" +
+		"on its drive and can be restored " + keep + ".\n" +
+		"The space is only freed once the quarantine is emptied."
+}
+
+// Ask again before cleaning up games whose saves aren't in Steam Cloud, then run onConfirm. Games without any are
+// cleaned up straight away.
+func confirmLocalSaves(appIDs []string, w fyne.Window, onConfirm func()) {
+	local := getLocalSaveGames(appIDs)
+	if len(local) == 0 {
+		onConfirm()
+		return
+	}
+
+	localGames, _ := getLocalGameList(context.Background())
+	var names []string
+	for _, appID := range local {
+		id, _ := strconv.Atoi(appID)
+		name := localGames[id].GameName
+		if name == "" {
+			name = "???"
+		}
+		names = append(names, fmt.Sprintf("%s - %s", appID, name))
+	}
+	dialog.ShowConfirm("Saves aren't in Steam Cloud", "These games have saves that are only on this device:\n\n"+
+		strings.Join(names, "\n")+"\n\nThey're backed up first, but once the quarantine is emptied the backup is\n"+
+		"the only copy left. Clean them up anyway?",
+		func(b bool) {
+			if b {
+				onConfirm()
+			}
+		}, w)
+}
@@ -17,9 +17,15 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
@@ -74,7 +80,13 @@ func syncGameDataWindow() {
 			populateGameDataWindow(w, leftSelected, rightSelected)
 		})
 		buttonBar := container.NewHSplit(cancelButton, submitButton)
-		selectionContainer = container.NewVBox(prompt, leftList, rightList, buttonBar)
+		// Every attached drive at once, each game's data goes to the drive it's installed on
+		allDrivesButton := widget.NewButton("Sync All Drives", func() {
+			selectionContainer.Hide()
+			w.CenterOnScreen()
+			populatePlacementWindow(w)
+		})
+		selectionContainer = container.NewVBox(prompt, leftList, rightList, buttonBar, allDrivesButton)
 	} else {
 		// Place a prompt near the top of the window
 		prompt := canvas.NewText("Not enough drives attached to sync data", Red)
@@ -110,14 +122,8 @@ func populateGameDataWindow(w fyne.Window, left string, right string) {
 		presentErrorInUI(err, w)
 	}
 
-	// Get the storage totals necessary for each side
-	data.getSpaceNeeded(left, right)
-
-	leftSpaceAvailable, err := getFreeSpace(left)
-	if err != nil {
-		presentErrorInUI(err, w)
-	}
-	rightSpaceAvailable, err := getFreeSpace(right)
+	// Get the storage totals necessary for each side, leaving out games that don't fit
+	spaces, err := data.planSpace(context.Background(), left, right)
 	if err != nil {
 		presentErrorInUI(err, w)
 	}
@@ -132,27 +138,10 @@ func populateGameDataWindow(w fyne.Window, left string, right string) {
 	rightDataStr := fmt.Sprintf("Data to be moved to %s", left)
 	rightSizeStr := fmt.Sprintf("Total Size: %.2fGB", float64(data.rightSize)/float64(GigabyteMultiplier))
 
-	// Deal with lack of space on left
-	if leftSpaceAvailable < data.rightSize {
-		leftCard = widget.NewCard(leftDataStr, "",
-			canvas.NewText("Error: Not enough space available on destination drive.", Red))
-		// Provide a button to close the window
-		syncDataButton = widget.NewButton("Close", func() {
-			w.Close()
-		})
-	}
-
-	// Deal with lack of space on right
-	if rightSpaceAvailable < data.leftSize {
-		rightCard = widget.NewCard(rightDataStr, "",
-			canvas.NewText("Error: Not enough space available on destination drive.", Red))
-		// Provide a button to close the window
-		syncDataButton = widget.NewButton("Close", func() {
-			w.Close()
-		})
-	}
+	// Show how each drive's usage changes, and what was left out for lack of space
+	spaceCard := createDriveSpaceCard(spaces)
 
-	leftList, rightList, err := getDataToMoveUI(data)
+	leftList, rightList, err := getDataToMoveUI(context.Background(), data)
 	// Deal with error
 	if err != nil {
 		// Create an error in each card if directories can't be listed
@@ -167,7 +156,7 @@ func populateGameDataWindow(w fyne.Window, left string, right string) {
 	}
 
 	// If there's anything to move left
-	if len(data.right) != 0 {
+	if !data.right.isEmpty() {
 		leftCard = widget.NewCard(rightDataStr, rightSizeStr, leftList)
 	} else {
 		leftCard = widget.NewCard(rightDataStr, "",
@@ -175,7 +164,7 @@ func populateGameDataWindow(w fyne.Window, left string, right string) {
 	}
 
 	// If there's anything to move right
-	if len(data.left) != 0 {
+	if !data.left.isEmpty() {
 		rightCard = widget.NewCard(leftDataStr, leftSizeStr, rightList)
 	} else {
 		rightCard = widget.NewCard(leftDataStr, "",
@@ -183,32 +172,51 @@ func populateGameDataWindow(w fyne.Window, left string, right string) {
 	}
 
 	// Create button if something can sync
-	if len(data.right) != 0 || len(data.left) != 0 {
+	if !data.right.isEmpty() || !data.left.isEmpty() {
 		syncDataButton = widget.NewButton("Confirm", func() {
 			// Do the actual sync
 			CryoUtils.InfoLog.Println("Sync data confirmed")
 			progress := widget.NewProgressBar()
 			CryoUtils.MoveDataProgressBar = progress
 			progress.Resize(fyne.NewSize(500, 50))
-			tempVBox := container.NewVBox(canvas.NewText("Moving items, please wait...", nil), progress)
-			widget.ShowModalPopUp(tempVBox, w.Canvas())
-			err = moveGameData(data, left, right)
-			if err != nil {
-				presentErrorInUI(err, w)
-			} else {
-				_, err := data.confirmDirectoryStatus(left, right)
-				if err != nil {
-					presentErrorInUI(err, w)
-				} else {
-					CryoUtils.InfoLog.Println("All data moved properly, printing success!")
+			progressText := widget.NewLabel("Calculating size...")
+			CryoUtils.MoveDataProgressText = progressText
+			ctx, cancel, modal := showCancellableProgress("Moving items, please wait...",
+				container.NewVBox(progress, progressText), w)
+			// Run in the background so the Cancel button stays responsive
+			go func() {
+				defer RecoverAndReport()
+				defer cancel()
+				err := WithOperationLock(OperationGameData, func() error {
+					return moveGameData(ctx, data, left, right, func(p CopyProgress) {
+						CryoUtils.MoveDataProgressBar.SetValue(p.Fraction())
+						CryoUtils.MoveDataProgressText.SetText(p.String())
+					})
+				})
+				modal.Hide()
+				if errors.Is(err, context.Canceled) {
 					dialog.ShowInformation(
-						"Success!",
-						"Data move completed, all game data is synced to the appropriate device.",
-						CryoUtils.MainWindow,
+						"Cancelled",
+						"Data move cancelled, games that weren't finished were left where they were.",
+						w,
 					)
-					w.Close()
+				} else if err != nil {
+					presentErrorInUI(err, w)
+				} else {
+					_, err := data.confirmDirectoryStatus(left, right)
+					if err != nil {
+						presentErrorInUI(err, w)
+					} else {
+						CryoUtils.InfoLog.Println("All data moved properly, printing success!")
+						dialog.ShowInformation(
+							"Success!",
+							"Data move completed, all game data is synced to the appropriate device.",
+							CryoUtils.MainWindow,
+						)
+						w.Close()
+					}
 				}
-			}
+			}()
 		})
 	} else {
 		// Otherwise, provide a button to close the window
@@ -225,7 +233,7 @@ func populateGameDataWindow(w fyne.Window, left string, right string) {
 	// Format the window
 	syncMain := container.NewGridWithColumns(1, leftCard, rightCard)
 	syncButtonBorder := container.NewGridWithColumns(2, cancelButton, syncDataButton)
-	syncLayout := container.NewBorder(nil, syncButtonBorder, nil, nil, syncMain)
+	syncLayout := container.NewBorder(nil, container.NewVBox(spaceCard, syncButtonBorder), nil, nil, syncMain)
 	w.SetContent(syncLayout)
 	w.Resize(fyne.NewSize(300, 450))
 	w.CenterOnScreen()
@@ -233,6 +241,294 @@ func populateGameDataWindow(w fyne.Window, left string, right string) {
 	w.Show()
 }
 
+// Show every move needed across all attached drives, and carry them out once confirmed.
+func populatePlacementWindow(w fyne.Window) {
+	p := widget.NewProgressBarInfinite()
+	d := dialog.NewCustom("Finding data to move...", "Dismiss", p, w)
+	d.Show()
+
+	plan, err := PlanPlacement(context.Background())
+	d.Hide()
+	if err != nil {
+		presentErrorInUI(err, w)
+		w.Close()
+		return
+	}
+	// Look the names up front, the API can't be waited on while the list draws
+	names := make(map[string]string)
+	for _, move := range plan.Moves {
+		gameInt, _ := strconv.Atoi(move.AppID)
+		names[move.AppID] = getGameName(context.Background(), gameInt)
+	}
+
+	var moveCard *widget.Card
+	if len(plan.Moves) != 0 {
+		var total int64
+		for _, move := range plan.Moves {
+			total += move.Size
+		}
+		moveList := widget.NewList(
+			func() int {
+				return len(plan.Moves)
+			},
+			func() fyne.CanvasObject {
+				return widget.NewLabel("Move")
+			},
+			func(i widget.ListItemID, o fyne.CanvasObject) {
+				o.(*widget.Label).SetText(fmt.Sprintf("%s - %s - %s", plan.Moves[i].AppID,
+					names[plan.Moves[i].AppID], plan.Moves[i]))
+			})
+		moveCard = widget.NewCard("Data to be moved", "Total Size: "+GetHumanByteSize(total), moveList)
+	} else {
+		moveCard = widget.NewCard("Data to be moved", "",
+			canvas.NewText("None! Every game's data is on the drive it's installed on.", Green))
+	}
+
+	// Show how each drive's usage changes, and what was left out for lack of space
+	spaceCard := createDriveSpaceCard(plan.Spaces)
+
+	cancelButton := widget.NewButton("Cancel", func() {
+		w.Close()
+	})
+	confirmButton := widget.NewButton("Confirm", func() {
+		CryoUtils.InfoLog.Println("Placement across all drives confirmed")
+		progress := widget.NewProgressBar()
+		CryoUtils.MoveDataProgressBar = progress
+		progressText := widget.NewLabel("Calculating size...")
+		CryoUtils.MoveDataProgressText = progressText
+		ctx, cancel, modal := showCancellableProgress("Moving items, please wait...",
+			container.NewVBox(progress, progressText), w)
+		// Run in the background so the Cancel button stays responsive
+		go func() {
+			defer RecoverAndReport()
+			defer cancel()
+			err := WithOperationLock(OperationGameData, func() error {
+				return ApplyPlacement(ctx, plan, func(p CopyProgress) {
+					CryoUtils.MoveDataProgressBar.SetValue(p.Fraction())
+					CryoUtils.MoveDataProgressText.SetText(p.String())
+				})
+			})
+			modal.Hide()
+			if errors.Is(err, context.Canceled) {
+				dialog.ShowInformation(
+					"Cancelled",
+					"Data move cancelled, games that weren't finished were left where they were.",
+					w,
+				)
+			} else if err != nil {
+				presentErrorInUI(err, w)
+			} else {
+				dialog.ShowInformation(
+					"Success!",
+					"Data move completed, all game data is on the drive each game is installed on.",
+					CryoUtils.MainWindow,
+				)
+				w.Close()
+			}
+		}()
+	})
+	if len(plan.Moves) == 0 {
+		confirmButton.Disable()
+	}
+
+	// Format the window
+	buttons := container.NewGridWithColumns(2, cancelButton, confirmButton)
+	layout := container.NewBorder(nil, container.NewVBox(spaceCard, buttons), nil, nil, moveCard)
+	w.SetContent(layout)
+	w.Resize(fyne.NewSize(500, 450))
+	w.CenterOnScreen()
+	w.RequestFocus()
+	w.Show()
+}
+
+// Window showing the space every game's data takes, in a table that can be sorted.
+func storageReportWindow() {
+	w := CryoUtils.App.NewWindow("Storage Report")
+
+	// Open with the sizes from the last time the data was measured, then fill in the exact sizes as they're measured
+	games, err := GetStorageReport(context.Background(), ReportSortSize, true)
+	if err != nil {
+		presentErrorInUI(err, CryoUtils.MainWindow)
+		return
+	}
+
+	// Guards games, which are measured again in the background while the window is open
+	var mu sync.Mutex
+	// The first row holds the column names
+	table := widget.NewTable(
+		func() (int, int) {
+			return len(games) + 1, len(reportColumns)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("Shadercache Link")
+		},
+		func(id widget.TableCellID, o fyne.CanvasObject) {
+			label := o.(*widget.Label)
+			if id.Row == 0 {
+				label.TextStyle = fyne.TextStyle{Bold: true}
+				label.SetText(reportColumns[id.Col])
+				return
+			}
+			label.TextStyle = fyne.TextStyle{}
+			mu.Lock()
+			row := getReportRow(games[id.Row-1], false)
+			mu.Unlock()
+			label.SetText(row[id.Col])
+		})
+	table.SetColumnWidth(1, 250)
+
+	sortSelect := widget.NewSelect(ReportSorts, func(sortBy string) {
+		mu.Lock()
+		sortStorageReport(games, sortBy)
+		mu.Unlock()
+		table.Refresh()
+	})
+	sortSelect.Selected = ReportSortSize
+
+	summary := widget.NewLabel("")
+	setSummary := func(status string) {
+		var total int64
+		for _, game := range games {
+			total += game.TotalSize()
+		}
+		summary.SetText(fmt.Sprintf("%d games, %s in total%s", len(games), GetHumanByteSize(total), status))
+	}
+	setSummary(" (measuring...)")
+	go func() {
+		defer RecoverAndReport()
+		refined := append([]GameInfo{}, games...)
+		err := refineGameSizes(context.Background(), refined)
+		if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+		}
+		byID := make(map[int]GameInfo)
+		for _, game := range refined {
+			byID[game.AppID] = game
+		}
+		// The rows may have been sorted differently in the meantime
+		mu.Lock()
+		for i, game := range games {
+			games[i].CompatSize, games[i].ShaderSize = byID[game.AppID].CompatSize, byID[game.AppID].ShaderSize
+		}
+		sortStorageReport(games, sortSelect.Selected)
+		setSummary("")
+		mu.Unlock()
+		table.Refresh()
+	}()
+	header := container.NewBorder(nil, nil, summary, container.NewHBox(widget.NewLabel("Sort by:"), sortSelect))
+	closeButton := widget.NewButton("Close", func() {
+		w.Close()
+	})
+
+	w.SetContent(container.NewBorder(header, closeButton, nil, nil, table))
+	w.Resize(fyne.NewSize(1100, 600))
+	w.CenterOnScreen()
+	w.RequestFocus()
+	w.Show()
+}
+
+// Window to move the data of hand-picked games to a single drive.
+func moveGamesWindow() {
+	w := CryoUtils.App.NewWindow("Move Games")
+
+	driveList, err := getListOfAttachedDrives()
+	if err != nil {
+		presentErrorInUI(err, w)
+		return
+	}
+	games, err := LocateGameData(context.Background())
+	if err != nil {
+		presentErrorInUI(err, w)
+		return
+	}
+	localGames, _ := getLocalGameList(context.Background())
+
+	// Each option starts with the appid, so the selection can be mapped back to the games
+	var options []string
+	for _, game := range games {
+		appID, _ := strconv.Atoi(game.AppID)
+		name := localGames[appID].GameName
+		if name == "" {
+			name = "???"
+		}
+		options = append(options, fmt.Sprintf("%s - %s - %s - %s", game.AppID, name, game.Location(),
+			GetHumanByteSize(game.Size)))
+	}
+	var selected []string
+	gameList := widget.NewCheckGroup(options, func(s []string) {
+		selected = nil
+		for i := range s {
+			selected = append(selected, strings.Split(s[i], " ")[0])
+		}
+	})
+
+	driveSelect := widget.NewSelect(driveList, func(string) {})
+	driveSelect.SetSelected(driveList[0])
+	dataRadio := widget.NewRadioGroup(DataTypes, func(string) {})
+	dataRadio.Horizontal = true
+	dataRadio.SetSelected(DataTypeBoth)
+	settings := container.NewVBox(
+		container.NewBorder(nil, nil, widget.NewLabel("Move to:"), nil, driveSelect),
+		container.NewBorder(nil, nil, widget.NewLabel("Data:"), nil, dataRadio),
+	)
+
+	moveCard := widget.NewCard("Move Games", "Choose which games' data to move, data already on the "+
+		"destination is left alone.", container.NewVScroll(gameList))
+
+	cancelButton := widget.NewButton("Cancel", func() {
+		w.Close()
+	})
+	moveButton := widget.NewButton("Move Selected", func() {
+		if len(selected) == 0 {
+			dialog.ShowInformation("Nothing selected", "Please select at least one game to move.", w)
+			return
+		}
+		to, dataType := driveSelect.Selected, dataRadio.Selected
+		CryoUtils.InfoLog.Println("Moving", selected, "to", to)
+
+		progress := widget.NewProgressBar()
+		CryoUtils.MoveDataProgressBar = progress
+		progressText := widget.NewLabel("Calculating size...")
+		CryoUtils.MoveDataProgressText = progressText
+		ctx, cancel, modal := showCancellableProgress("Moving items, please wait...",
+			container.NewVBox(progress, progressText), w)
+		// Run in the background so the Cancel button stays responsive
+		go func() {
+			defer RecoverAndReport()
+			defer cancel()
+			err := WithOperationLock(OperationGameData, func() error {
+				return MoveGames(ctx, selected, to, dataType, func(p CopyProgress) {
+					CryoUtils.MoveDataProgressBar.SetValue(p.Fraction())
+					CryoUtils.MoveDataProgressText.SetText(p.String())
+				})
+			})
+			modal.Hide()
+			if errors.Is(err, context.Canceled) {
+				dialog.ShowInformation(
+					"Cancelled",
+					"Data move cancelled, games that weren't finished were left where they were.",
+					w,
+				)
+			} else if err != nil {
+				presentErrorInUI(err, w)
+			} else {
+				dialog.ShowInformation("Success!", "The selected games were moved to "+to+".",
+					CryoUtils.MainWindow)
+				w.Close()
+			}
+		}()
+	})
+
+	// Format the window
+	moveButtons := container.NewGridWithColumns(2, cancelButton, moveButton)
+	moveLayout := container.NewBorder(settings, moveButtons, nil, nil, moveCard)
+	w.SetContent(moveLayout)
+	w.Resize(fyne.NewSize(500, 450))
+	w.CenterOnScreen()
+	w.RequestFocus()
+	w.Show()
+}
+
 func cleanupDataWindow() {
 	var cleanupCard *widget.Card
 	var cleanupButton, cancelButton *widget.Button
@@ -241,7 +537,7 @@ func cleanupDataWindow() {
 	w := CryoUtils.App.NewWindow("Clean Game Data")
 
 	var removeList []string
-	cleanupList, err := createGameDataList()
+	cleanupList, err := createGameDataList(context.Background())
 	if err != nil {
 		presentErrorInUI(err, CryoUtils.MainWindow)
 	}
@@ -263,56 +559,53 @@ func cleanupDataWindow() {
 	cancelButton = widget.NewButton("Cancel", func() {
 		w.Close()
 	})
-	cleanupButton = widget.NewButton("Delete Selected", func() {
-		dialog.ShowConfirm("Are you sure?", "Are you sure you want to delete these files?\n\n"+
-			"Please be sure to back up any Non-Steam-Cloud save games before\n"+
-			"deleting them using this tool, as any selected will be lost.",
-			func(b bool) {
-				if b {
-					possibleLocations, err := getListOfDataAllDataLocations()
-					if err != nil {
-						CryoUtils.ErrorLog.Println(err)
-						presentErrorInUI(err, CryoUtils.MainWindow)
-					}
+	// Clean up the given games, once any with saves only on the Deck have been confirmed separately
+	cleanup := func(appIDs []string) {
+		confirmLocalSaves(appIDs, w, func() {
+			locations, err := getListOfDataAllDataLocations()
+			if err != nil {
+				CryoUtils.ErrorLog.Println(err)
+				presentErrorInUI(err, CryoUtils.MainWindow)
+			}
 
-					removeGameData(removeList, possibleLocations)
+			err = WithOperationLock(OperationGameData, func() error {
+				return removeGameData(context.Background(), appIDs, locations)
+			})
+			if err != nil {
+				presentErrorInUI(err, w)
+				return
+			}
 
-					dialog.ShowInformation(
-						"Success!",
-						"Process completed!",
-						CryoUtils.MainWindow,
-					)
-					w.Close()
-				} else {
+			dialog.ShowInformation(
+				"Success!",
+				"Process completed!",
+				CryoUtils.MainWindow,
+			)
+			w.Close()
+		})
+	}
+
+	cleanupButton = widget.NewButton("Clean Selected", func() {
+		dialog.ShowConfirm("Are you sure?", "Are you sure you want to clean up these files?\n\n"+
+			getQuarantineNotice(),
+			func(b bool) {
+				if !b {
 					w.Close()
+					return
 				}
+				cleanup(removeList)
 			}, w)
 	})
 
-	cleanAllUninstalled := widget.NewButton("Delete All Uninstalled", func() {
-		dialog.ShowConfirm("Are you sure?", "Are you sure you want to delete these files?\n\n"+
-			"Please be sure to back up any Non-Steam-Cloud save games before\n"+
-			"deleting them using this tool, as any selected will be lost.",
+	cleanAllUninstalled := widget.NewButton("Clean All Uninstalled", func() {
+		dialog.ShowConfirm("Are you sure?", "Are you sure you want to clean up these files?\n\n"+
+			getQuarantineNotice(),
 			func(b bool) {
 				if !b {
 					w.Close()
+					return
 				}
-
-				locations, err := getListOfDataAllDataLocations()
-				if err != nil {
-					CryoUtils.ErrorLog.Println(err)
-					presentErrorInUI(err, CryoUtils.MainWindow)
-				}
-
-				removeGameData(getUninstalledGamesData(), locations)
-
-				dialog.ShowInformation(
-					"Success!",
-					"Process completed!",
-					CryoUtils.MainWindow,
-				)
-				w.Close()
-
+				cleanup(getUninstalledGamesData(context.Background()))
 			}, w)
 
 	})
@@ -357,26 +650,37 @@ func swapSizeWindow() {
 	// Provide a button to submit the choice
 	swapResizeButton := widget.NewButton("Resize Swap File", func() {
 		progress := widget.NewProgressBarInfinite()
-		d := dialog.NewCustom("Resizing Swap File, please be patient..."+
-			"(This can take up to 30 minutes)", "Quit", progress,
-			w,
-		)
-		d.Show()
-		err = changeSwapSizeGUI(chosenSize)
-		if err != nil {
-			d.Hide()
-			presentErrorInUI(err, w)
-		} else {
-			d.Hide()
-			dialog.ShowInformation(
-				"Success!",
-				"Process completed! You can verify the file is resized by\n"+
-					"running 'ls -lash /home/swapfile' or 'swapon -s' in Konsole.",
-				CryoUtils.MainWindow,
-			)
-			CryoUtils.refreshSwapContent()
-			w.Close()
-		}
+		ctx, cancel, modal := showCancellableProgress("Resizing Swap File, please be patient..."+
+			"(This can take up to 30 minutes)", progress, w)
+		// Run in the background so the Cancel button stays responsive
+		go func() {
+			defer RecoverAndReport()
+			defer cancel()
+			err := WithOperationLock(OperationSwap, func() error {
+				return changeSwapSizeGUI(ctx, chosenSize)
+			})
+			modal.Hide()
+			if errors.Is(err, context.Canceled) {
+				dialog.ShowInformation(
+					"Cancelled",
+					"Swap resize cancelled. If the resize had already started,\n"+
+						"the previous swap size was restored.",
+					w,
+				)
+				CryoUtils.refreshSwapContent()
+			} else if err != nil {
+				presentErrorInUI(err, w)
+			} else {
+				dialog.ShowInformation(
+					"Success!",
+					"Process completed! You can verify the file is resized by\n"+
+						"running 'ls -lash /home/swapfile' or 'swapon -s' in Konsole.",
+					CryoUtils.MainWindow,
+				)
+				CryoUtils.refreshSwapContent()
+				w.Close()
+			}
+		}()
 	})
 
 	// Make a progress bar and hide it
@@ -394,17 +698,34 @@ func swapSizeWindow() {
 }
 
 // Note: Having a separate function for this is hacky, but necessary for progress bar functionality
-func changeSwapSizeGUI(size int) error {
+func changeSwapSizeGUI(ctx context.Context, size int) error {
+	// Nothing has changed yet, so stopping here is free
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	err := checkSwapSpace(size)
+	if err != nil {
+		return err
+	}
+	previous := recordSwapResize(size)
 	// Disable swap temporarily
 	renewSudoAuth()
 	CryoUtils.InfoLog.Println("Disabling swap temporarily...")
-	err := disableSwap()
+	err = disableSwap()
 	if err != nil {
 		return err
 	}
 	// Resize the file
 	renewSudoAuth()
-	err = resizeSwapFile(size)
+	err = resizeSwapFile(ctx, size)
+	if ctx.Err() != nil {
+		renewSudoAuth()
+		restoreErr := restorePreviousSwapFile(previous)
+		if restoreErr != nil {
+			return restoreErr
+		}
+		return ctx.Err()
+	}
 	if err != nil {
 		return err
 	}
@@ -440,7 +761,9 @@ func swappinessWindow() {
 	// Provide a button to submit the choice
 	swappinessChangeButton := widget.NewButton("Change Swappiness", func() {
 		renewSudoAuth()
-		err := ChangeSwappiness(chosenSwappiness)
+		err := WithOperationLock(OperationTweaks, func() error {
+			return ChangeSwappiness(chosenSwappiness)
+		})
 		if err != nil {
 			presentErrorInUI(err, w)
 		} else {
@@ -461,3 +784,475 @@ func swappinessWindow() {
 	w.RequestFocus()
 	w.Show()
 }
+
+// Preference holding the last crash report the user was told about.
+const lastCrashReportPreference = "lastCrashReport"
+
+// Offer to show a crash report from the last session, then to deal with any interrupted operation.
+func (app *Config) offerCrashRecovery() {
+	report, err := getLatestCrashReport()
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to look for crash reports:", err)
+	}
+	if report == "" || report == app.App.Preferences().String(lastCrashReportPreference) {
+		app.offerIncompleteMoves()
+		return
+	}
+	app.App.Preferences().SetString(lastCrashReportPreference, report)
+
+	dialog.ShowConfirm("CryoUtilities Crashed",
+		"CryoUtilities closed unexpectedly last time.\n"+
+			"A crash report was saved to:\n"+report+"\n\n"+
+			"Would you like to view it?",
+		func(b bool) {
+			if b {
+				crashReportWindow(report)
+			}
+			app.offerIncompleteMoves()
+		},
+		app.MainWindow,
+	)
+}
+
+// Show the contents of a crash report, selectable so it can be copied into a bug report.
+func crashReportWindow(path string) {
+	w := CryoUtils.App.NewWindow("Crash Report")
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		presentErrorInUI(err, CryoUtils.MainWindow)
+		return
+	}
+	prompt := canvas.NewText("Please include this report when reporting the crash.", nil)
+	prompt.TextStyle = fyne.TextStyle{Bold: true}
+	report := widget.NewMultiLineEntry()
+	report.SetText(string(contents))
+	report.Wrapping = fyne.TextWrapOff
+
+	closeButton := widget.NewButton("Close", func() {
+		w.Close()
+	})
+
+	w.SetContent(container.NewBorder(prompt, closeButton, nil, nil, report))
+	w.Resize(fyne.NewSize(700, 450))
+	w.CenterOnScreen()
+	w.RequestFocus()
+	w.Show()
+}
+
+// Offer to resume or roll back an operation that was running when the last session ended.
+func (app *Config) offerInterruptedOperation() {
+	holder, err := getInterruptedOperation()
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to check for an interrupted operation:", err)
+		return
+	}
+	if holder == nil {
+		return
+	}
+	CryoUtils.InfoLog.Println("Found interrupted operation:", describeOperation(holder))
+	interruptedOperationWindow(holder)
+}
+
+func interruptedOperationWindow(holder *LockHolder) {
+	w := CryoUtils.App.NewWindow("Interrupted Operation")
+
+	prompt := canvas.NewText("The last "+holder.Operation+" didn't finish.", Red)
+	prompt.TextSize, prompt.TextStyle = 18, fyne.TextStyle{Bold: true}
+	explanation := widget.NewLabel("It was started at " + holder.Started.Format("2006-01-02 15:04:05") +
+		" and stopped before completing.\nChoose what to do with it, or dismiss this to leave things as they are.")
+
+	dismissButton := widget.NewButton("Dismiss", func() {
+		err := clearInterruptedOperation()
+		if err != nil {
+			presentErrorInUI(err, w)
+			return
+		}
+		w.Close()
+	})
+	buttons := container.NewVBox()
+	var swapRecovered func()
+
+	if canResumeOperation(holder) {
+		buttons.Add(widget.NewButton("Resume", func() {
+			if holder.Operation == OperationGameData {
+				// Start the sync over, the preview shows what is left to move.
+				err := clearInterruptedOperation()
+				if err != nil {
+					presentErrorInUI(err, w)
+					return
+				}
+				w.SetTitle("Sync Game Data")
+				populateGameDataWindow(w, holder.Details["left"], holder.Details["right"])
+				return
+			}
+			runRecoveryAction(w, OperationSwap, "Resuming the swap change, please be patient...",
+				func(ctx context.Context) error {
+					return resumeSwapOperation(ctx, holder)
+				}, swapRecovered)
+		}))
+	}
+	if canRollBackOperation(holder) {
+		buttons.Add(widget.NewButton("Roll Back", func() {
+			runRecoveryAction(w, OperationSwap, "Restoring the previous swap file, please be patient...",
+				func(ctx context.Context) error {
+					return rollBackSwapOperation(ctx, holder)
+				}, swapRecovered)
+		}))
+	}
+	buttons.Add(dismissButton)
+	swapRecovered = func() {
+		dialog.ShowInformation("Success!", "The interrupted operation was recovered.", CryoUtils.MainWindow)
+		w.Close()
+	}
+
+	w.SetContent(container.NewVBox(prompt, explanation, buttons))
+	w.CenterOnScreen()
+	w.RequestFocus()
+	w.Show()
+}
+
+// Run a recovery action in the background under the operation lock, reporting failures over the given window.
+func runRecoveryAction(w fyne.Window, operation string, title string, action func(ctx context.Context) error,
+	onSuccess func()) {
+	progress := widget.NewProgressBarInfinite()
+	ctx, cancel, modal := showCancellableProgress(title, progress, w)
+	go func() {
+		defer RecoverAndReport()
+		defer cancel()
+		renewSudoAuth()
+		err := WithOperationLock(operation, func() error {
+			return action(ctx)
+		})
+		modal.Hide()
+		CryoUtils.refreshAllContent()
+		if errors.Is(err, context.Canceled) {
+			dialog.ShowInformation("Cancelled", "Recovery cancelled.", w)
+		} else if err != nil {
+			presentErrorInUI(err, w)
+		} else {
+			onSuccess()
+		}
+	}()
+}
+
+// Offer to finish or undo game data moves that were cut off, then carry on with any interrupted operation.
+func (app *Config) offerIncompleteMoves() {
+	moves, err := getIncompleteMoves()
+	if err != nil {
+		CryoUtils.ErrorLog.Println("Unable to read the move journal:", err)
+	}
+	if len(moves) == 0 {
+		app.offerInterruptedOperation()
+		return
+	}
+	CryoUtils.InfoLog.Println("Found", len(moves), "incomplete game data moves")
+	incompleteMovesWindow(moves)
+}
+
+func incompleteMovesWindow(moves []*MoveJournalEntry) {
+	w := CryoUtils.App.NewWindow("Incomplete Data Moves")
+
+	prompt := canvas.NewText("Some game data moves didn't finish.", Red)
+	prompt.TextSize, prompt.TextStyle = 18, fyne.TextStyle{Bold: true}
+	explanation := widget.NewLabel("Resume finishes moving the game's data, Roll Back puts it where it was.\n" +
+		"Until then, these games may not launch properly.")
+
+	remaining := len(moves)
+	rows := container.NewVBox()
+	for _, move := range moves {
+		move := move
+		var row *fyne.Container
+		// Once every move is dealt with, carry on with whatever else the last session left behind.
+		done := func() {
+			row.Hide()
+			remaining--
+			if remaining == 0 {
+				dialog.ShowInformation("Success!", "All incomplete data moves were recovered.",
+					CryoUtils.MainWindow)
+				w.Close()
+				CryoUtils.offerInterruptedOperation()
+			}
+		}
+		label := widget.NewLabel(fmt.Sprintf("%s: %s to %s (stopped at %s)", move.AppID,
+			filepath.Dir(move.FromCompatDir), filepath.Dir(move.ToCompatDir), move.Step))
+		resumeButton := widget.NewButton("Resume", func() {
+			runRecoveryAction(w, OperationGameData, "Resuming move of "+move.AppID+"...",
+				func(ctx context.Context) error {
+					return resumeMove(ctx, move)
+				}, done)
+		})
+		rollBackButton := widget.NewButton("Roll Back", func() {
+			runRecoveryAction(w, OperationGameData, "Rolling back move of "+move.AppID+"...",
+				func(ctx context.Context) error {
+					return rollBackMove(ctx, move)
+				}, done)
+		})
+		row = container.NewBorder(nil, nil, nil, container.NewHBox(resumeButton, rollBackButton), label)
+		rows.Add(row)
+	}
+
+	w.SetContent(container.NewBorder(container.NewVBox(prompt, explanation), nil, nil, nil,
+		container.NewVScroll(rows)))
+	w.Resize(fyne.NewSize(700, 400))
+	w.CenterOnScreen()
+	w.RequestFocus()
+	w.Show()
+}
+
+// List the broken compatdata and shadercache links, with a way to repair each one.
+func linkRepairWindow() {
+	w := CryoUtils.App.NewWindow("Repair Game Data Links")
+
+	links, err := ScanDataLinks()
+	if err != nil {
+		presentErrorInUI(err, CryoUtils.MainWindow)
+		return
+	}
+	var broken []DataLink
+	for _, link := range links {
+		if link.IsBroken() {
+			broken = append(broken, link)
+		}
+	}
+
+	closeButton := widget.NewButton("Close", func() {
+		w.Close()
+	})
+	if len(broken) == 0 {
+		prompt := canvas.NewText("None! Every link points to its data.", Green)
+		prompt.TextSize, prompt.TextStyle = 18, fyne.TextStyle{Bold: true}
+		w.SetContent(container.NewVBox(prompt, closeButton))
+		w.CenterOnScreen()
+		w.Show()
+		return
+	}
+
+	prompt := canvas.NewText("These links point to data that isn't there:", nil)
+	prompt.TextSize, prompt.TextStyle = 18, fyne.TextStyle{Bold: true}
+	explanation := widget.NewLabel("Re-point uses a copy found on an attached card, Empty creates a blank " +
+		"folder so Steam\nrebuilds it, and Remove deletes the link.")
+
+	rows := container.NewVBox()
+	for _, link := range broken {
+		link := link
+		var row *fyne.Container
+		repair := func(action string) {
+			err := WithOperationLock(OperationGameData, func() error {
+				return RepairDataLink(link, action)
+			})
+			if err != nil {
+				presentErrorInUI(err, w)
+				return
+			}
+			row.Hide()
+		}
+
+		text := fmt.Sprintf("%s: %s\n%s", link.AppID, link.Status, link.Target)
+		if link.Candidate != "" {
+			text += "\nFound at " + link.Candidate
+		}
+		repointButton := widget.NewButton("Re-point", func() { repair(LinkRepairRepoint) })
+		if link.Candidate == "" {
+			repointButton.Disable()
+		}
+		emptyButton := widget.NewButton("Empty", func() { repair(LinkRepairRecreate) })
+		removeButton := widget.NewButton("Remove", func() { repair(LinkRepairRemove) })
+		row = container.NewBorder(nil, nil, nil,
+			container.NewHBox(repointButton, emptyButton, removeButton), widget.NewLabel(text))
+		rows.Add(row)
+	}
+
+	w.SetContent(container.NewBorder(container.NewVBox(prompt, explanation), closeButton, nil, nil,
+		container.NewVScroll(rows)))
+	w.Resize(fyne.NewSize(700, 450))
+	w.CenterOnScreen()
+	w.RequestFocus()
+	w.Show()
+}
+
+// Window listing the game data set aside by cleanups, to restore it or delete it for good.
+func quarantineWindow() {
+	w := CryoUtils.App.NewWindow("Quarantine")
+
+	quarantined, err := ListQuarantine()
+	if err != nil {
+		presentErrorInUI(err, CryoUtils.MainWindow)
+		return
+	}
+
+	// How long data is kept, offering the current value even if it was set to something else from the CLI
+	dayOptions := []string{"0", "7", "14", "30", "90"}
+	current := strconv.Itoa(GetQuarantineDays())
+	if !Contains(dayOptions, current) {
+		dayOptions = append(dayOptions, current)
+	}
+	daysSelect := widget.NewSelect(dayOptions, func(days string) {
+		value, _ := strconv.Atoi(days)
+		err := SetQuarantineDays(value)
+		if err != nil {
+			presentErrorInUI(err, w)
+		}
+	})
+	daysSelect.Selected = current
+	daysBox := container.NewHBox(widget.NewLabel("Days to keep data (0 keeps it until emptied):"), daysSelect)
+
+	closeButton := widget.NewButton("Close", func() {
+		w.Close()
+	})
+	if len(quarantined) == 0 {
+		prompt := canvas.NewText("Nothing is in quarantine.", Green)
+		prompt.TextSize, prompt.TextStyle = 18, fyne.TextStyle{Bold: true}
+		w.SetContent(container.NewVBox(prompt, daysBox, closeButton))
+		w.CenterOnScreen()
+		w.Show()
+		return
+	}
+
+	prompt := canvas.NewText("This data was cleaned up, but can still be restored:", nil)
+	prompt.TextSize, prompt.TextStyle = 18, fyne.TextStyle{Bold: true}
+
+	var total int64
+	rows := container.NewVBox()
+	for _, data := range quarantined {
+		data := data
+		total += data.Size
+		var row *fyne.Container
+		restoreButton := widget.NewButton("Restore", func() {
+			err := WithOperationLock(OperationGameData, func() error {
+				return restoreQuarantinedData(data)
+			})
+			if err != nil {
+				presentErrorInUI(err, w)
+				return
+			}
+			row.Hide()
+		})
+		text := fmt.Sprintf("%s: %s\nCleaned up %s, %s", data.AppID, data.Source,
+			data.QuarantinedAt.Format(time.DateTime), GetHumanByteSize(data.Size))
+		row = container.NewBorder(nil, nil, nil, restoreButton, widget.NewLabel(text))
+		rows.Add(row)
+	}
+
+	emptyButton := widget.NewButton(fmt.Sprintf("Empty Quarantine (%s)", GetHumanByteSize(total)), func() {
+		dialog.ShowConfirm("Are you sure?", "Everything in quarantine will be deleted for good,\n"+
+			"including any non-Steam-Cloud save games in it.",
+			func(b bool) {
+				if !b {
+					return
+				}
+				var freed int64
+				err := WithOperationLock(OperationGameData, func() error {
+					var err error
+					freed, err = EmptyQuarantine(context.Background(), false)
+					return err
+				})
+				if err != nil {
+					presentErrorInUI(err, w)
+					return
+				}
+				dialog.ShowInformation("Success!", fmt.Sprintf("Freed %s.", GetHumanByteSize(freed)),
+					CryoUtils.MainWindow)
+				w.Close()
+			}, w)
+	})
+
+	w.SetContent(container.NewBorder(container.NewVBox(prompt, daysBox),
+		container.NewGridWithColumns(2, closeButton, emptyButton), nil, nil, container.NewVScroll(rows)))
+	w.Resize(fyne.NewSize(700, 450))
+	w.CenterOnScreen()
+	w.RequestFocus()
+	w.Show()
+}
+
+// Window to back up a game's saves, and to restore them from any backup.
+func backupsWindow() {
+	w := CryoUtils.App.NewWindow("Save Backups")
+
+	backups, err := ListBackups()
+	if err != nil {
+		presentErrorInUI(err, CryoUtils.MainWindow)
+		return
+	}
+	games, err := LocateGameData(context.Background())
+	if err != nil {
+		presentErrorInUI(err, CryoUtils.MainWindow)
+		return
+	}
+	localGames, _ := getLocalGameList(context.Background())
+
+	// Only games with a prefix have saves to back up, each option starts with the appid
+	var options []string
+	for _, game := range games {
+		if game.CompatDrive == "" {
+			continue
+		}
+		appID, _ := strconv.Atoi(game.AppID)
+		name := localGames[appID].GameName
+		if name == "" {
+			name = "???"
+		}
+		options = append(options, fmt.Sprintf("%s - %s", game.AppID, name))
+	}
+	gameSelect := widget.NewSelect(options, nil)
+	gameSelect.PlaceHolder = "Choose a game"
+	backupButton := widget.NewButton("Back Up", func() {
+		if gameSelect.Selected == "" {
+			return
+		}
+		appID := strings.Split(gameSelect.Selected, " ")[0]
+		var manifest *BackupManifest
+		err := WithOperationLock(OperationGameData, func() error {
+			var err error
+			manifest, err = BackupGame(context.Background(), appID)
+			return err
+		})
+		if err != nil {
+			presentErrorInUI(err, w)
+			return
+		}
+		if manifest == nil {
+			dialog.ShowInformation("Nothing to back up", "No saves or settings were found in this game's prefix.", w)
+			return
+		}
+		dialog.ShowInformation("Success!", fmt.Sprintf("Backed up %d files.", manifest.Files), CryoUtils.MainWindow)
+		w.Close()
+	})
+	backupBox := container.NewBorder(nil, nil, nil, backupButton, gameSelect)
+
+	rows := container.NewVBox()
+	for _, backup := range backups {
+		backup := backup
+		restoreButton := widget.NewButton("Restore", func() {
+			dialog.ShowConfirm("Are you sure?", "Saves in the game's prefix will be replaced with the ones in "+
+				"this backup.", func(b bool) {
+				if !b {
+					return
+				}
+				err := WithOperationLock(OperationGameData, func() error {
+					_, err := RestoreBackup(context.Background(), backup.AppID, backup.Archive)
+					return err
+				})
+				if err != nil {
+					presentErrorInUI(err, w)
+					return
+				}
+				dialog.ShowInformation("Success!", fmt.Sprintf("Restored %d files.", backup.Files), w)
+			}, w)
+		})
+		rows.Add(container.NewBorder(nil, nil, nil, restoreButton, widget.NewLabel(backup.String())))
+	}
+	var backupList fyne.CanvasObject = container.NewVScroll(rows)
+	if len(backups) == 0 {
+		backupList = widget.NewLabel("No backups yet. One is made automatically before a game's data is cleaned up.")
+	}
+
+	closeButton := widget.NewButton("Close", func() {
+		w.Close()
+	})
+	w.SetContent(container.NewBorder(backupBox, closeButton, nil, nil, backupList))
+	w.Resize(fyne.NewSize(700, 450))
+	w.CenterOnScreen()
+	w.RequestFocus()
+	w.Show()
+}
@@ -17,10 +17,9 @@
 package internal
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -30,6 +29,7 @@ import (
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/widget"
 	"github.com/moby/sys/mountinfo"
 	"golang.org/x/sys/unix"
@@ -51,6 +51,9 @@ type Config struct {
 	MainWindow                    fyne.Window
 	SwapResizeProgressBar         *widget.ProgressBar
 	MoveDataProgressBar           *widget.ProgressBar
+	MoveDataProgressText          *widget.Label
+	MainTabs                      *container.AppTabs
+	PendingTab                    string
 	HomeContainer                 *fyne.Container
 	GameDataContainer             *fyne.Container
 	MemoryContainer               *fyne.Container
@@ -62,6 +65,18 @@ type Config struct {
 	DefragButton                  *widget.Button
 	PageLockUnfairnessButton      *widget.Button
 	VRAMButton                    *widget.Button
+	RecommendedButton             *widget.Button
+	StockButton                   *widget.Button
+	SwapResizeButton              *widget.Button
+	SwappinessChangeButton        *widget.Button
+	SyncDataButton                *widget.Button
+	MoveGamesButton               *widget.Button
+	CleanupDataButton             *widget.Button
+	RepairLinksButton             *widget.Button
+	StorageReportButton           *widget.Button
+	QuarantineButton              *widget.Button
+	BackupsButton                 *widget.Button
+	LockStatusText                *canvas.Text
 	UserPassword                  string
 	SwapFileLocation              string
 }
@@ -74,27 +89,17 @@ func getFreeSpace(path string) (int64, error) {
 	err := unix.Statfs(path, &stat)
 	if err != nil {
 		CryoUtils.ErrorLog.Println(err)
-		return 0, fmt.Errorf("error getting free space")
+		return 0, fmt.Errorf("error getting free space for %s: %w", path, err)
 	}
 	return int64(stat.Bfree * uint64(stat.Bsize)), nil
 }
 
-func getDirectorySize(path string) int64 {
-	var size int64
-	_ = filepath.Walk(path, func(_ string, info os.FileInfo, _ error) error {
-		if !info.IsDir() {
-			size += info.Size()
-		}
-		return nil
-	})
-	return size
-}
-
 func isSymbolicLink(path string) bool {
 	fi, err := os.Lstat(path)
 	if err != nil {
-		CryoUtils.ErrorLog.Println("Unable to determine if file was symlink:", path)
-		panic(err)
+		// The path can disappear mid-move, anything that can't be read isn't a symlink.
+		CryoUtils.ErrorLog.Println("Unable to determine if file was symlink:", path, err)
+		return false
 	}
 
 	if fi.Mode()&os.ModeSymlink != 0 {
@@ -103,7 +108,8 @@ func isSymbolicLink(path string) bool {
 	return false
 }
 
-func contains(s []string, str string) bool {
+// Contains Check whether a slice holds a string.
+func Contains(s []string, str string) bool {
 	for _, v := range s {
 		if v == str {
 			return true
@@ -113,13 +119,24 @@ func contains(s []string, str string) bool {
 	return false
 }
 
-func waitForDeletion(path string, directory string) {
-	for {
-		if !doesDirectoryExist(path, directory) {
-			break
+// Wait until the directory disappears from path, giving up after DeletionTimeout or when cancelled.
+func waitForDeletion(ctx context.Context, path string, directory string) error {
+	ctx, cancel := context.WithTimeout(ctx, DeletionTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for doesDirectoryExist(path, directory) {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("timed out waiting for %s to be deleted", filepath.Join(path, directory))
+			}
+			return ctx.Err()
+		case <-ticker.C:
 		}
-		time.Sleep(time.Second)
 	}
+	return nil
 }
 
 // Checks the path variable until directory is no longer found, then exits.
@@ -175,9 +192,9 @@ func writeFile(path string, contents string) error {
 	}
 
 	// Move the completed file to final location.
-	_, err = exec.Command("sudo", "mv", tempPath, path).Output()
+	_, err = runCommand(exec.Command("sudo", "mv", tempPath, path))
 	if err != nil {
-		return fmt.Errorf("error moving temp file to final location")
+		return fmt.Errorf("error moving temp file to final location: %w", err)
 	}
 
 	return nil
@@ -185,7 +202,7 @@ func writeFile(path string, contents string) error {
 
 func removeFile(path string) error {
 	CryoUtils.InfoLog.Println("Removing", path)
-	_, err := exec.Command("sudo", "rm", path).Output()
+	_, err := runCommand(exec.Command("sudo", "rm", path))
 	if err != nil {
 		CryoUtils.ErrorLog.Println("Couldn't delete", path, ", likely missing.")
 	}
@@ -243,10 +260,9 @@ func removeElementFromStringSlice(str string, slice []string) []string {
 
 func getUnitStatus(param string) (string, error) {
 	var output string
-	cmd, err := exec.Command("sudo", "cat", UnitMatrix[param]).Output()
+	cmd, err := runCommand(exec.Command("sudo", "cat", UnitMatrix[param]))
 	if err != nil {
-		CryoUtils.ErrorLog.Println(err)
-		return "nil", err
+		return "nil", fmt.Errorf("error reading %s: %w", UnitMatrix[param], err)
 	}
 	// This is just to get the actual value in units which present as a list.
 	if strings.Contains(string(cmd), "[") {
@@ -289,22 +305,19 @@ func removeUnitFile(param string) error {
 
 func setUnitValue(param string, value string) error {
 	CryoUtils.InfoLog.Println("Writing", value, "for param", param, "to memory.")
-	// This mess is the only way I could find to push directly to unit files, without requiring
+	// Piping into sudo tee is the only way I could find to push directly to unit files, without requiring
 	// a sudo password on installation to change capabilities.
-	echoCmd := exec.Command("echo", value)
 	teeCmd := exec.Command("sudo", "tee", UnitMatrix[param])
-	reader, writer := io.Pipe()
-	var buf bytes.Buffer
-	echoCmd.Stdout = writer
-	teeCmd.Stdin = reader
-	teeCmd.Stdout = &buf
-	echoCmd.Start()
-	teeCmd.Start()
-	echoCmd.Wait()
-	writer.Close()
-	teeCmd.Wait()
-	reader.Close()
-	io.Copy(os.Stdout, &buf)
+	teeCmd.Stdin = strings.NewReader(value + "\n")
+	_, err := runCommand(teeCmd)
+	if err != nil {
+		var cmdErr *CommandError
+		// The kernel answers values it doesn't accept with EINVAL
+		if errors.As(err, &cmdErr) && strings.Contains(cmdErr.Stderr, "Invalid argument") {
+			return fmt.Errorf("error writing %s to %s: %w: %w", value, UnitMatrix[param], ErrKernelRejected, err)
+		}
+		return fmt.Errorf("error writing %s to %s: %w", value, UnitMatrix[param], err)
+	}
 
 	return nil
 }
@@ -320,18 +333,56 @@ func getHumanVRAMSize(size int) string {
 	return text
 }
 
-func removeGameData(removeList []string, locations []string) {
+// GetHumanByteSize Converts a size in bytes to a human-readable format.
+func GetHumanByteSize(size int64) string {
+	switch {
+	case size >= int64(GigabyteMultiplier):
+		return fmt.Sprintf("%.2fGB", float64(size)/float64(GigabyteMultiplier))
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1fKB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+// Back up the saves of every listed game, then move its data from every location into its drive's quarantine,
+// stopping between games if cancelled. Anything quarantined longer than the retention period is deleted first.
+func removeGameData(ctx context.Context, removeList []string, locations []string) error {
+
+	// Make room by deleting what has been kept long enough
+	_, err := EmptyQuarantine(ctx, true)
+	if err != nil {
+		CryoUtils.ErrorLog.Println(err)
+	}
 
-	CryoUtils.InfoLog.Println("Removing the following content:")
+	var errs []error
+	CryoUtils.InfoLog.Println("Quarantining the following content:")
 	for i := range removeList {
+		if ctx.Err() != nil {
+			CryoUtils.InfoLog.Println("Removal cancelled before", removeList[i])
+			return ctx.Err()
+		}
+		// Leave running games alone, and carry on with the rest
+		if isGameRunning(removeList[i]) {
+			errs = append(errs, fmt.Errorf("skipped %s: %w", removeList[i], ErrSteamRunning))
+			continue
+		}
+		// Keep a game's data if its saves couldn't be backed up
+		err := backupBeforeCleanup(ctx, removeList[i])
+		if err != nil {
+			CryoUtils.ErrorLog.Println(err)
+			errs = append(errs, fmt.Errorf("skipped %s: %w", removeList[i], err))
+			continue
+		}
 		for j := range locations {
 			path := filepath.Join(locations[j], removeList[i])
-			CryoUtils.InfoLog.Println(path)
-			err := os.RemoveAll(path)
+			err := quarantineData(ctx, path)
 			if err != nil {
 				CryoUtils.ErrorLog.Println(err)
-				presentErrorInUI(err, CryoUtils.MainWindow)
+				errs = append(errs, err)
 			}
 		}
 	}
+	return errors.Join(errs...)
 }